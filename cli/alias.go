@@ -0,0 +1,262 @@
+// User defined aliases for command lines e.g. alias ll='ls | sort'
+
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/charlesetsmith/testgocui/screen"
+)
+
+// Aliasfile - Where aliases are kept between sessions,
+// defaults to $XDG_CONFIG_HOME/testgocui/aliases (~/.config/testgocui/aliases)
+var Aliasfile = defaultaliasfile()
+
+func defaultaliasfile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "testgocui", "aliases")
+}
+
+// Aliases and what they are replaced with, aliasMu also covers writing Aliasfile
+var aliasMu sync.RWMutex
+var aliases = map[string]string{}
+
+func init() {
+	Register(Cmd{Name: "alias", Usage: "alias [name[='command args']]...",
+		Help:     "Define aliases, replacing name at the start of a command with the command line, or list them",
+		Long:     "Aliases are expanded at the start of each command of a command line and saved for the next session.",
+		Examples: []string{"alias ll='ls | sort'", "alias", "alias ll"},
+		Fn:       alias, Complete: CompleteAliases, Category: "Aliases"})
+	Register(Cmd{Name: "unalias", Help: "Remove the aliases", Fn: unalias,
+		Complete: CompleteAliases, Category: "Aliases", Positional: &Positional{Name: "name", Min: 1, Max: -1}})
+}
+
+// Is s usable as an alias name, a word with nothing the command line would split or quote
+func aliasname(s string) bool {
+	for _, r := range s {
+		if unicode.IsSpace(r) || strings.ContainsRune("='\"\\|>;$", r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// SetAlias - Make name an alias for the command line value, which can have pipes and redirection but not ;
+func SetAlias(name string, value string) error {
+	if !aliasname(name) {
+		return fmt.Errorf("invalid alias name %q", name)
+	}
+	tokens, err := Lex(value)
+	if err != nil {
+		return fmt.Errorf("alias %s: %w", name, err)
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("alias %s is empty", name)
+	}
+	for _, t := range tokens {
+		if t.Op && t.Val == ";" {
+			return fmt.Errorf("alias %s: only one command line, no ;", name)
+		}
+	}
+	aliasMu.Lock()
+	defer aliasMu.Unlock()
+	aliases[name] = value
+	return nil
+}
+
+// UnsetAlias - Remove the alias name
+func UnsetAlias(name string) error {
+	aliasMu.Lock()
+	defer aliasMu.Unlock()
+	if _, ok := aliases[name]; !ok {
+		return fmt.Errorf("no alias %s", name)
+	}
+	delete(aliases, name)
+	return nil
+}
+
+// LookupAlias - What the alias name is replaced with
+func LookupAlias(name string) (string, bool) {
+	aliasMu.RLock()
+	defer aliasMu.RUnlock()
+	value, ok := aliases[name]
+	return value, ok
+}
+
+// AliasNames - Sorted names of the aliases
+func AliasNames() []string {
+	aliasMu.RLock()
+	defer aliasMu.RUnlock()
+	names := make([]string, 0, len(aliases))
+	for n := range aliases {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CompleteAliases - Completer for arguments that are alias names
+func CompleteAliases(args []string, word string) []string {
+	return prefixed(AliasNames(), word)
+}
+
+// ExpandAliases - Replace an alias at the start of the command line, and after each |, with its
+// command line. Aliases in what it is replaced with are expanded too except for one already being
+// expanded, so alias ls='ls | sort' works. A quoted or escaped name (e.g. \ls) is not expanded.
+func ExpandAliases(s string) string {
+	return expandaliases(s, nil)
+}
+
+func expandaliases(s string, expanding []string) string {
+	tokens, err := Lex(s)
+	if err != nil { // Run reports it
+		return s
+	}
+	runes := []rune(s)
+	// From the end back so the columns of the words before are still right
+	for i := len(tokens) - 1; i >= 0; i-- {
+		t := tokens[i]
+		if t.Op || (i > 0 && !(tokens[i-1].Op && tokens[i-1].Val == "|")) { // Not a command name
+			continue
+		}
+		start := t.Col - 1
+		end := start + len([]rune(t.Val))
+		if end > len(runes) || string(runes[start:end]) != t.Val ||
+			(end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune("|>;", runes[end])) {
+			continue // Quoted or escaped
+		}
+		value, ok := LookupAlias(t.Val)
+		if !ok || contains(expanding, t.Val) {
+			continue
+		}
+		value = expandaliases(value, append(expanding, t.Val))
+		runes = append(append(append([]rune{}, runes[:start]...), []rune(value)...), runes[end:]...)
+	}
+	return string(runes)
+}
+
+// Is s in list
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// alias 'name=command args'... or alias [name]... to list them
+func alias(e *Env) error {
+	var s string
+
+	names := e.Args[1:]
+	if len(names) == 0 {
+		names = AliasNames()
+	}
+	changed := false
+	for _, a := range names {
+		if name, value, ok := strings.Cut(a, "="); ok {
+			if err := SetAlias(name, value); err != nil {
+				return err
+			}
+			changed = true
+			continue
+		}
+		value, ok := LookupAlias(a)
+		if !ok {
+			return fmt.Errorf("no alias %s", a)
+		}
+		s += aliasline(a, value) + "\n"
+	}
+	screen.Fprintf(e.Out, "output", "%s", s)
+	if changed {
+		return SaveAliases()
+	}
+	return nil
+}
+
+// unalias <name>...
+func unalias(e *Env) error {
+	for _, name := range e.Args[1:] {
+		if err := UnsetAlias(name); err != nil {
+			return err
+		}
+	}
+	return SaveAliases()
+}
+
+// The alias command that defines name e.g. alias ll='ls | sort'
+func aliasline(name string, value string) string {
+	return "alias " + name + "='" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// LoadAliases - Define the aliases kept in Aliasfile, a missing file is not an error
+func LoadAliases() error {
+	if Aliasfile == "" {
+		return nil
+	}
+	f, err := os.Open(Aliasfile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words, err := Lex(line)
+		if err != nil || len(words) < 2 || words[0].Val != "alias" {
+			return fmt.Errorf("%s:%d: not an alias", Aliasfile, n)
+		}
+		for _, w := range words[1:] {
+			name, value, _ := strings.Cut(w.Val, "=")
+			if err := SetAlias(name, value); err != nil {
+				return fmt.Errorf("%s:%d: %w", Aliasfile, n, err)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// SaveAliases - Write all of the aliases to Aliasfile
+func SaveAliases() error {
+	if Aliasfile == "" {
+		return nil
+	}
+	aliasMu.Lock()
+	defer aliasMu.Unlock()
+	names := make([]string, 0, len(aliases))
+	for n := range aliases {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	s := "# Aliases saved by testgocui, alias and unalias rewrite this file\n"
+	for _, n := range names {
+		s += aliasline(n, aliases[n]) + "\n"
+	}
+	if err := os.MkdirAll(filepath.Dir(Aliasfile), 0755); err != nil {
+		return err
+	}
+	tmp := Aliasfile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(s), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, Aliasfile)
+}
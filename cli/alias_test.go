@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAliases(t *testing.T) {
+	Aliasfile = "" // Keep the aliases made here out of the user's file
+	defer func() {
+		UnsetAlias("hi")
+		UnsetAlias("hd")
+	}()
+	testDocmd(t, []docmdTest{
+		{cmd: "alias hi='echo hello'; hi there; \\hi", msg: "hello there\n", err: "Invalid command: hi\n", failed: true},
+		{cmd: "alias hd='head 1'; echo a | hd", msg: "a\n"},
+		{cmd: "alias hd", msg: "alias hd='head 1'\n"},
+		{cmd: "alias 'h|d=echo'", err: `invalid alias name "h|d"`, failed: true},
+		{cmd: "alias 'hx=echo a; echo b'", err: "no ;", failed: true},
+		{cmd: "unalias nosuch", err: "no alias nosuch", failed: true},
+	})
+}
+
+func TestExpandAliases(t *testing.T) {
+	for name, value := range map[string]string{"ll": "ls -l", "lp": "lq x", "lq": "lp y", "srt": "srt | sort"} {
+		if err := SetAlias(name, value); err != nil {
+			t.Fatal(err)
+		}
+		defer UnsetAlias(name)
+	}
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"ll /tmp", "ls -l /tmp"},
+		{"echo ll | ll > ll", "echo ll | ls -l > ll"},
+		{`\ll; 'll'; "ll"`, `\ll; 'll'; "ll"`}, // Quoted or escaped
+		{"lp", "lp y x"},                       // Each alias is only expanded once
+		{"srt a", "srt | sort a"},
+		{"nosuch", "nosuch"},
+	}
+	for _, tt := range tests {
+		if got := ExpandAliases(tt.s); got != tt.want {
+			t.Errorf("ExpandAliases(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+// The aliases saved in Aliasfile are the same when they are loaded again
+func TestAliasfile(t *testing.T) {
+	Aliasfile = filepath.Join(t.TempDir(), "testgocui", "aliases")
+	defer func() { Aliasfile = "" }()
+	if err := SetAlias("sq", `echo "it's" | head 1`); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveAliases(); err != nil {
+		t.Fatal(err)
+	}
+	UnsetAlias("sq")
+	if err := LoadAliases(); err != nil {
+		t.Fatal(err)
+	}
+	defer UnsetAlias("sq")
+	if value, _ := LookupAlias("sq"); value != `echo "it's" | head 1` {
+		t.Errorf("sq loaded as %q", value)
+	}
+}
@@ -3,12 +3,18 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/charlesetsmith/testgocui/screen"
-	"github.com/jroimartin/gocui"
 )
 
 // Cmdinfo - Previos Command history, prompt info
@@ -19,109 +25,371 @@ type Cmdhist struct {
 	Curline  int // What is the current command line # we are on
 }
 
-type Cmdfunc func(*gocui.Gui, []string, Cmdhist)
+// Cmdfunc - Function run for a command, e.Args has its arguments and its output goes to e.Out.
+// Filters read the output of the previous stage of the pipeline from e.In.
+type Cmdfunc func(e *Env) error
 
+// Cmd - A command, the names it answers to, its help and the function that runs it
 type Cmd struct {
-	Usage string
-	Help  string
-}
-
-var Commands = map[string]Cmd{
-	"ca":    {Usage: "ca [arg]...", Help: "Command Example a"},
-	"cb":    {Usage: "cb [arg]...", Help: "Command Example b"},
-	"cc":    {Usage: "cc [arg]...", Help: "Command Example c"},
-	"buf":   {Usage: "buf", Help: "Show Buffer"},
-	"ls":    {Usage: "ls", Help: "History of commands entered"},
-	"quit":  {Usage: "quit", Help: "Bye!"},
-	"exit":  {Usage: "exit", Help: "Bye!"},
-	"help":  {Usage: "help", Help: "List of available commands"},
-	"usage": {Usage: "usage", Help: "List of available commands"},
-	"?":     {Usage: "?", Help: "List of available commands"},
-}
-
-var Commandfuncs = map[string]Cmdfunc{
-	"ca":    cmda,
-	"cb":    cmdb,
-	"cc":    cmdc,
-	"buf":   cmdbuf,
-	"ls":    ls,
-	"quit":  exit,
-	"exit":  exit,
-	"help":  usage,
-	"usage": usage,
-	"?":     usage,
+	Name        string   // Name typed at the prompt
+	Aliases     []string // Other names for the same command
+	Usage       string
+	Help        string
+	Fn          Cmdfunc
+	Filter      Cmdfunc     // Set instead of Fn for commands that can only follow a |
+	Complete    Completer   // Tab completion of the arguments, nil for none
+	Category    string      // Used to group commands in help
+	Subcommands []Cmd       // e.g. show and clear of "packet show|clear", Fn if set runs when none is given
+	Long        string      // More about it for "help <command>"
+	Examples    []string    // Command lines showing how to use it
+	Flags       []Flag      // Options it takes, parsed before it runs
+	Positional  *Positional // How many arguments it takes after the flags, nil if it checks them itself
+
+	path string          // Full name e.g. "packet show"
+	subs map[string]*Cmd // Subcommands by name and alias
+}
+
+// Path - Full name of the command, e.g. "packet show" for a subcommand
+func (c *Cmd) Path() string {
+	return c.path
+}
+
+// Sub - The subcommand called name, or with Abbrev the only one starting with name, nil if there is none
+func (c *Cmd) Sub(name string) *Cmd {
+	s, _ := resolve(c.subs, name)
+	return s
+}
+
+// SubNames - Sorted names of the subcommands
+func (c *Cmd) SubNames() []string {
+	names := make([]string, 0, len(c.Subcommands))
+	for _, s := range c.Subcommands {
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Commands - All registered commands keyed by Name
+var Commands = map[string]*Cmd{}
+
+// Names and aliases to their command
+var lookup = map[string]*Cmd{}
+
+// Protect Commands & lookup as commands can be registered from other packages
+var regMu sync.RWMutex
+
+// Register - Add a command to the registry.
+// Call it from an init() so conflicts are caught at startup, a missing
+// Fn (or Filter or Subcommands) or a Name/Alias already in use panics.
+func Register(c Cmd) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	c.check(c.Name)
+	names := append([]string{c.Name}, c.Aliases...)
+	for _, n := range names {
+		if prev, ok := lookup[n]; ok {
+			log.Panicf("cli.Register: %q of command %s already used by command %s", n, c.Name, prev.Name)
+		}
+	}
+	cmd := &c
+	Commands[c.Name] = cmd
+	for _, n := range names {
+		lookup[n] = cmd
+	}
+}
+
+// Check the command called path and its subcommands and index them
+func (c *Cmd) check(path string) {
+	if c.Name == "" {
+		log.Panicf("cli.Register: command in %q has no name (usage %q)", path, c.Usage)
+	}
+	if c.Fn != nil && c.Filter != nil || c.Fn == nil && c.Filter == nil && len(c.Subcommands) == 0 {
+		log.Panicf("cli.Register: command %s needs one of Fn, Filter or Subcommands", path)
+	}
+	names := append([]string{c.Name}, c.Aliases...)
+	for i, n := range names {
+		if n == "" || strings.ContainsAny(n, " \t") {
+			log.Panicf("cli.Register: command %s has invalid name or alias %q", path, n)
+		}
+		for _, m := range names[:i] {
+			if m == n {
+				log.Panicf("cli.Register: command %s lists %q twice", path, n)
+			}
+		}
+	}
+	c.path = path
+	if len(c.Subcommands) > 0 {
+		c.Subcommands = append([]Cmd(nil), c.Subcommands...) // Our own copy to index
+		c.subs = map[string]*Cmd{}
+		for i := range c.Subcommands {
+			s := &c.Subcommands[i]
+			s.check(path + " " + s.Name)
+			for _, n := range append([]string{s.Name}, s.Aliases...) {
+				if prev, ok := c.subs[n]; ok {
+					log.Panicf("cli.Register: %q of command %s already used by command %s", n, s.path, prev.path)
+				}
+				c.subs[n] = s
+			}
+		}
+	}
+	c.checkflags()
+	if c.Usage == "" {
+		words := []string{path}
+		if c.Filter != nil {
+			words = []string{"... |", path}
+		}
+		switch {
+		case len(c.subs) > 0 && c.Fn == nil && c.Filter == nil:
+			words = append(words, "<"+strings.Join(c.SubNames(), "|")+">")
+		case len(c.subs) > 0:
+			words = append(words, "["+strings.Join(c.SubNames(), "|")+"]")
+		}
+		for _, f := range c.Flags {
+			words = append(words, f.usage())
+		}
+		if c.Positional != nil && c.Positional.Max != 0 {
+			words = append(words, c.Positional.usage())
+		}
+		c.Usage = strings.Join(words, " ")
+	}
+}
+
+// Lookup - Find a command by its name or one of its aliases, nil if there is none.
+// With Abbrev set it is also found by a prefix no other command has.
+func Lookup(name string) *Cmd {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	c, _ := resolve(lookup, name)
+	return c
+}
+
+// Find - The command named by the words e.g. "packet", "show" and how many of them name it,
+// it stops at the first word that is not a subcommand. nil if words[0] is not a command.
+func Find(words []string) (*Cmd, int) {
+	if len(words) == 0 {
+		return nil, 0
+	}
+	c := Lookup(words[0])
+	if c == nil {
+		return nil, 0
+	}
+	n := 1
+	for ; n < len(words) && c.Sub(words[n]) != nil; n++ {
+		c = c.Sub(words[n])
+	}
+	return c, n
+}
+
+// The built in commands, add your own with Register from your own package
+func init() {
+	Register(Cmd{Name: "ca", Help: "Command Example a, with flags", Fn: cmda, Category: "Examples",
+		Long:     "Shows its arguments n times, waiting between each, to show how a command declares its flags.",
+		Examples: []string{"ca one two", "ca -n 3 -every 1s -colour bold:cyan tick"},
+		Flags: []Flag{
+			{Name: "n", Type: Int, Default: "1", Help: "Times to show it"},
+			{Name: "every", Type: Duration, Help: "Wait between each"},
+			{Name: "colour", Type: Colour, Default: "bold:green", Help: "Colour to show it in"},
+			{Name: "prefix", Type: String, Default: "Command A", Help: "Text before the arguments"},
+		},
+		Positional: &Positional{Name: "arg", Max: -1}})
+	Register(Cmd{Name: "cb", Help: "Command Example b", Fn: cmdb, Category: "Examples",
+		Positional: &Positional{Name: "arg", Max: -1}})
+	Register(Cmd{Name: "cc", Help: "Command Example c", Fn: cmdc, Category: "Examples",
+		Positional: &Positional{Name: "arg", Max: -1}})
+	Register(Cmd{Name: "sleep", Help: "Wait secs (default 10), CtrlC interrupts", Fn: sleep, Category: "Examples",
+		Long:       "Shows each second as it goes by. Run it then start other commands, jobs lists them and kill n stops one.",
+		Examples:   []string{"sleep 5", "sleep 60; echo done"},
+		Positional: &Positional{Name: "secs", Max: 1}})
+	Register(Cmd{Name: "buf", Help: "Show Buffer of the view (default cmd)", Fn: cmdbuf,
+		Complete: CompleteWords(screen.Views...), Category: "Views", Positional: &Positional{Name: "view", Max: 1}})
+	Register(Cmd{Name: "ls", Help: "History of commands entered", Fn: ls, Category: "History", Positional: &Positional{}})
+	Register(Cmd{Name: "jobs", Help: "List running commands", Fn: jobscmd, Category: "Jobs", Positional: &Positional{}})
+	Register(Cmd{Name: "kill", Help: "Interrupt the command entered on line n", Fn: kill,
+		Complete: CompleteJobs, Category: "Jobs", Positional: &Positional{Name: "n", Min: 1, Max: -1}})
+	Register(Cmd{Name: "wait", Help: "Wait for the command on line n, or all commands, to finish",
+		Fn: wait, Complete: CompleteJobs, Category: "Jobs", Positional: &Positional{Name: "n", Max: -1}})
+	Register(Cmd{Name: "fg", Help: "Make the command on line n the one CtrlC interrupts",
+		Fn: fg, Complete: CompleteJobs, Category: "Jobs", Positional: &Positional{Name: "n", Min: 1, Max: 1}})
+	Register(Cmd{Name: "exit", Aliases: []string{"quit"}, Help: "Bye!", Fn: exit, Category: "General",
+		Long: "Stops the rest of the command line and any script it is in, then the front end quits. " +
+			"Without the gui status is the exit status.",
+		Positional: &Positional{Name: "status", Max: 1}})
 }
 
 // The different command line input handlers
 
-// cmda [args]...
-func cmda(g *gocui.Gui, args []string, cmds Cmdhist) {
-	screen.MsgPrintln(g, "green_black", "Command A", args)
+// cmda [-n n] [-every duration] [-colour colour] [-prefix text] [args]...
+func cmda(e *Env) error {
+	for i := 0; i < e.Int("n"); i++ {
+		if i > 0 && e.Duration("every") > 0 {
+			select {
+			case <-e.Done():
+				return e.Err()
+			case <-time.After(e.Duration("every")):
+			}
+		}
+		screen.Fprintln(e.Out, e.Flag("colour"), e.Flag("prefix"), e.Args)
+	}
+	return nil
 }
 
 // cmdb [args]...
-func cmdb(g *gocui.Gui, args []string, cmds Cmdhist) {
-	screen.MsgPrintln(g, "green_black", "Command B", args)
+func cmdb(e *Env) error {
+	screen.Fprintln(e.Out, "output", "Command B", e.Args)
+	return nil
 }
 
 // cmdc [args]...
-func cmdc(g *gocui.Gui, args []string, cmds Cmdhist) {
-	screen.MsgPrintln(g, "green_black", "Command B", args)
+func cmdc(e *Env) error {
+	screen.Fprintln(e.Out, "output", "Command B", e.Args)
+	return nil
+}
+
+// sleep [secs] - Example of a long running command that can be interrupted with CtrlC
+func sleep(e *Env) error {
+	secs := 10
+	if len(e.Args) > 1 {
+		var err error
+		if secs, err = strconv.Atoi(e.Args[1]); err != nil || secs < 0 {
+			return fmt.Errorf("invalid number of seconds %s", e.Args[1])
+		}
+	}
+	for i := 1; i <= secs; i++ {
+		select {
+		case <-e.Done():
+			return e.Err()
+		case <-time.After(time.Second):
+			screen.Fprintf(e.Out, "output", "sleep %d/%d\n", i, secs)
+		}
+	}
+	return nil
 }
 
 // ls - list the history of commands to the msg window
-func ls(g *gocui.Gui, args []string, cmds Cmdhist) {
+func ls(e *Env) error {
 	var s string
 
-	for i := 0; i < len(cmds.Commands); i++ {
-		s += fmt.Sprintf("%d=%s\n", i, cmds.Commands[i])
+	for i := 0; i < len(e.Hist.Commands); i++ {
+		s += fmt.Sprintf("%d=%s\n", i, e.Hist.Commands[i])
 	}
-	screen.MsgPrintln(g, "cyan_black", s)
+	screen.Fprintf(e.Out, "output", "%s", s)
+	return nil
 }
 
-func cmdbuf(g *gocui.Gui, args []string, cmds Cmdhist) {
-	// var b string
-	v, _ := g.View("cmd")
-	s := v.Buffer()
-	screen.MsgPrintln(g, "", s)
+// buf [view] - Show what is in a view
+func cmdbuf(e *Env) error {
+	vname := "cmd"
+	if len(e.Args) > 1 {
+		vname = e.Args[1]
+	}
+	b, ok := e.Sink.(Bufferer)
+	if !ok {
+		return errors.New("there are no views to show")
+	}
+	s, err := b.Buffer(vname)
+	if err != nil {
+		return err
+	}
+	screen.Fprintf(e.Out, "", "%s", s)
+	return nil
 }
 
 // Quit saratoga
-func exit(g *gocui.Gui, args []string, cmds Cmdhist) {
-	if len(args) == 1 { // exit 0
-		screen.MsgPrintln(g, "green_black", "Gocui Good Bye!")
-		return
+func exit(e *Env) error {
+	if len(e.Args) == 1 {
+		screen.Fprintln(e.Out, "output", "Gocui Good Bye!")
+		return &ExitError{Status: -1}
+	}
+	n, err := strconv.Atoi(e.Args[1])
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid exit status %s", e.Args[1])
 	}
+	return &ExitError{Status: n}
 }
 
-// usage - sort list usage of available commands and help
-func usage(g *gocui.Gui, args []string, cmds Cmdhist) {
-	s := "CtrlSpace - Rotate Between Views\nCtrlP - Show/Hode Packet View\n\n"
-	keys := make([]string, 0, len(Commands))
-	for k := range Commands {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	for _, k := range keys {
-		s += fmt.Sprintf("%s: %s\n", Commands[k].Usage, Commands[k].Help)
+// ExitError - Returned by exit, it stops the command line and any script it is in so the front end can quit.
+// Status is -1 when exit was not given one.
+type ExitError struct {
+	Status int
+}
+
+func (e *ExitError) Error() string {
+	if e.Status < 0 {
+		return "exit"
 	}
-	screen.MsgPrintln(g, "cyan_black", s)
+	return fmt.Sprintf("exit %d", e.Status)
 }
 
 /* ************************************************************************** */
 
-// Docmd -- Execute the command entered
-func Docmd(g *gocui.Gui, s string, cmds Cmdhist) {
-	if s == "" { // Handle just return
-		return
+// Docmd -- Execute the command entered, cmd; cmd runs one after the other
+// Output goes to the sink's msg writer, errors are shown on its err writer and the last returned.
+// An interrupted command returns ctx.Err() and exit stops the rest returning its *ExitError.
+func Docmd(ctx context.Context, sink Sink, s string, cmds Cmdhist) error {
+	var xerr *ExitError
+
+	lines, err := Split(s)
+	if err != nil { // Run will find the same problem and we report it
+		lines = []string{s}
 	}
-	s = strings.TrimSpace(s)  // Get rid of leading and trailing whitespace
-	vals := strings.Fields(s) // Split each field into a slice of strings
-	// Lookup the command and execute it if it is a valid command!
-	if Commands[vals[0]].Help != "" {
-		fn := Commandfuncs[vals[0]]
-		fn(g, vals, cmds)
-		return
+	for _, line := range lines {
+		err = Run(ctx, sink, sink.MsgWriter(), line, cmds)
+		if errors.As(err, &xerr) {
+			return err
+		}
+		Report(sink, "", line, err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// Run - Execute the command line with its output to out unless it is redirected after
+// expanding its aliases and $variables, the caller reports any error
+func Run(ctx context.Context, sink Sink, out io.Writer, s string, cmds Cmdhist) (err error) {
+	if expanded := ExpandAliases(s); expanded != s {
+		// Show where the problem is in what the aliases were replaced with
+		defer func() {
+			var serr *SyntaxError
+			if errors.As(err, &serr) {
+				err = fmt.Errorf("%s\n%*s^ %s", expanded, serr.Col-1, "", serr.Msg)
+			}
+		}()
+		s = expanded
+	}
+	// Split into words handling quotes, escapes and $variables
+	tokens, err := lex(s, func(name string) (string, bool) { return LookupVar(name, cmds) })
+	if err == nil && len(tokens) == 0 { // Handle just return or only white space
+		return nil
+	}
+	defer func() { setstatus(err) }() // For $?
+	if err != nil {
+		return err
+	}
+	cl, err := parse(tokens)
+	if err != nil {
+		return err
+	}
+	return cl.run(ctx, sink, out, cmds)
+}
+
+// Report - Show the error from running command line s, where says where it came from (e.g. file:line: )
+func Report(sink Sink, where string, s string, err error) {
+	var serr *SyntaxError
+	var ierr *InvalidError
+	var xerr *ExitError
+	switch {
+	case err == nil, errors.As(err, &xerr):
+	case errors.Is(err, context.Canceled): // The job finished notice says so
+	case errors.As(err, &serr): // Point at where it went wrong
+		screen.Fprintf(sink.ErrWriter(), "error", "%s%s\n%*s^ %s\n", where, s, len(where)+serr.Col-1, "", serr.Msg)
+	case errors.As(err, &ierr) && (ierr.Parent != nil || len(ierr.Ambiguous) > 0):
+		screen.Fprintln(sink.ErrWriter(), "error", where, ierr.Error())
+	case errors.As(err, &ierr):
+		screen.Fprintln(sink.ErrWriter(), "error", where, "Invalid command: ", ierr.Name+ierr.hint())
+	default:
+		screen.Fprintln(sink.ErrWriter(), "error", where, err.Error())
 	}
-	screen.MsgPrintln(g, "red_black", "Invalid command:", vals[0])
 }
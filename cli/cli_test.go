@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/charlesetsmith/testgocui/screen"
+)
+
+// docmdTest - A line for Docmd and what it shows in the views without the colours
+type docmdTest struct {
+	cmd    string
+	msg    string // All of the msg output
+	err    string // In the err output
+	failed bool   // Docmd returns an error
+}
+
+// Run each line with Docmd writing to a Buffer and check what it shows
+func testDocmd(t *testing.T, tests []docmdTest) {
+	t.Helper()
+	for _, tt := range tests {
+		b := &Buffer{}
+		err := Docmd(context.Background(), b, tt.cmd, Cmdhist{})
+		msg, _ := b.Buffer("msg")
+		errs, _ := b.Buffer("err")
+		if msg = screen.Uncolour(msg); msg != tt.msg {
+			t.Errorf("%s: msg %q, want %q", tt.cmd, msg, tt.msg)
+		}
+		if errs = screen.Uncolour(errs); tt.err == "" && errs != "" || !strings.Contains(errs, tt.err) {
+			t.Errorf("%s: err %q, want %q", tt.cmd, errs, tt.err)
+		}
+		if (err != nil) != tt.failed {
+			t.Errorf("%s: returned %v", tt.cmd, err)
+		}
+	}
+}
+
+func TestDocmd(t *testing.T) {
+	testDocmd(t, []docmdTest{
+		{cmd: "echo a  'b  c'", msg: "a b  c\n"},
+		{cmd: "", msg: ""},
+		{cmd: "echo one; echo two", msg: "one\ntwo\n"},
+		{cmd: "echo 'a", err: "^ ", failed: true},
+	})
+}
@@ -0,0 +1,175 @@
+// Tab completion of command names and their arguments
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Completer - Candidates for the word being typed, args are the words before it
+// starting with the command name
+type Completer func(args []string, word string) []string
+
+// Names - Sorted names and aliases of the commands, filters if filter is set
+func Names(filter bool) []string {
+	var names []string
+
+	regMu.RLock()
+	for n, c := range lookup {
+		if (c.Filter != nil) == filter {
+			names = append(names, n)
+		}
+	}
+	regMu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// The words in list starting with word
+func prefixed(list []string, word string) []string {
+	var matches []string
+
+	for _, w := range list {
+		if strings.HasPrefix(w, word) {
+			matches = append(matches, w)
+		}
+	}
+	return matches
+}
+
+// CompleteWords - Completer for arguments from a fixed list of words
+func CompleteWords(words ...string) Completer {
+	return func(args []string, word string) []string {
+		return prefixed(words, word)
+	}
+}
+
+// CompleteCommands - Completer for arguments that are command names then their subcommands
+func CompleteCommands(args []string, word string) []string {
+	if len(args) > 1 {
+		if c, n := Find(args[1:]); c != nil && n == len(args)-1 {
+			return prefixed(c.SubNames(), word)
+		}
+		return nil
+	}
+	return prefixed(append(Names(false), Names(true)...), word)
+}
+
+// CompleteFiles - Completer for arguments that are file names, directories end in /
+func CompleteFiles(args []string, word string) []string {
+	var matches []string
+
+	dir, base := filepath.Split(word)
+	readdir := dir
+	if readdir == "" {
+		readdir = "."
+	}
+	entries, err := os.ReadDir(readdir)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), base) || (strings.HasPrefix(e.Name(), ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		name := dir + e.Name()
+		if e.IsDir() {
+			name += string(filepath.Separator)
+		}
+		matches = append(matches, name)
+	}
+	return matches
+}
+
+// CompleteJobs - Completer for arguments that are running job numbers
+func CompleteJobs(args []string, word string) []string {
+	var ids []string
+
+	for _, j := range Jobs() {
+		ids = append(ids, strconv.Itoa(j.Id))
+	}
+	return prefixed(ids, word)
+}
+
+// Complete - Candidates for the last word of the command line and the column,
+// starting at 1, that word starts at so it can be replaced with one of them.
+// Command names complete the first word of each stage of a pipeline, subcommand names the word
+// after a command with them, -name its declared flags (and the values of an Enum flag) and the
+// command's Complete function its arguments, after > or >> file names.
+func Complete(line string) (int, []string) {
+	tokens, err := Lex(line)
+	if err != nil { // Can't complete inside a quote
+		return 0, nil
+	}
+	// Are we part way through the last word or starting a new one,
+	// if we are in the word typing another character would add to it
+	col := len([]rune(line)) + 1
+	word := ""
+	more, _ := Lex(line + "x")
+	if n := len(tokens); n > 0 && !tokens[n-1].Op && len(more) == n {
+		col = tokens[n-1].Col
+		word = tokens[n-1].Val
+		tokens = tokens[:n-1]
+	}
+	// The words of this stage of the pipeline before the one being completed
+	var args []string
+	filter := false
+	for _, t := range tokens {
+		switch {
+		case !t.Op:
+			args = append(args, t.Val)
+		case t.Val == "|":
+			args = nil
+			filter = true
+		default: // > or >>
+			return col, CompleteFiles(nil, word)
+		}
+	}
+	if len(args) == 0 {
+		names := Names(filter)
+		if !filter {
+			names = append(names, AliasNames()...)
+			sort.Strings(names)
+		}
+		return col, prefixed(names, word)
+	}
+	c, n := Find(args)
+	if c == nil {
+		return col, nil
+	}
+	if prev := args[len(args)-1]; n < len(args) && strings.HasPrefix(prev, "-") { // The value of a flag
+		if f := c.flag(prev[1:]); f != nil && f.Type == Enum {
+			return col, prefixed(f.Values, word)
+		} else if f != nil && f.Type == Colour {
+			return col, prefixed(styles(), word)
+		} else if f != nil && f.Type != Bool {
+			return col, nil
+		}
+	}
+	switch {
+	case n == len(args) && len(c.subs) > 0: // The subcommand
+		return col, prefixed(c.SubNames(), word)
+	case strings.HasPrefix(word, "-") && len(c.Flags) > 0:
+		return col, prefixed(c.flagnames(), word)
+	case c.Complete != nil:
+		return col, c.Complete(args, word)
+	}
+	return col, nil
+}
+
+// Quote - Backslash escape the characters the command line would otherwise split or remove
+func Quote(s string) string {
+	var q strings.Builder
+
+	for _, r := range s {
+		if strings.ContainsRune(" \t'\"\\|>;$", r) {
+			q.WriteRune('\\')
+		}
+		q.WriteRune(r)
+	}
+	return q.String()
+}
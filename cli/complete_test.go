@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		line string
+		col  int // Where the word being completed starts
+		want []string
+	}{
+		{"hel", 1, []string{"help"}},
+		{"  ech", 3, []string{"echo"}},
+		{"echo a | he", 10, []string{"head"}}, // Filters after a |
+		{"echo a | hel", 10, nil},
+		{"help pa", 6, []string{"packet"}},
+		{"packet s", 8, []string{"save", "show"}}, // Subcommands
+		{"help packet s", 13, []string{"save", "show"}},
+		{"ca -", 4, []string{"-n", "-every", "-colour", "-prefix"}}, // As declared
+		{"ca -n ", 7, nil},                                          // A flag's value
+		{"ca -colour warn", 12, []string{"warning"}},                // Styles
+		{"echo 'a", 0, nil},                                         // Inside a quote
+	}
+	for _, tt := range tests {
+		col, got := Complete(tt.line)
+		if col != tt.col || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Complete(%q) = %d %q, want %d %q", tt.line, col, got, tt.col, tt.want)
+		}
+	}
+}
+
+func TestCompleteFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"my file", "my;file$x", ".hidden"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "mydir"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	dir += string(filepath.Separator)
+	tests := []struct {
+		line string
+		col  int
+		want []string
+	}{
+		{"echo a > " + Quote(dir+"my"), 10, []string{dir + "my file", dir + "my;file$x", dir + "mydir/"}},
+		{"echo a >>" + Quote(dir+"my f"), 10, []string{dir + "my file"}},
+		{"packet save " + Quote(dir+"my;"), 13, []string{dir + "my;file$x"}},
+		{"packet save " + Quote(dir+"."), 13, []string{dir + ".hidden"}},
+		{"packet save " + Quote(dir), 13, []string{dir + "my file", dir + "my;file$x", dir + "mydir/"}},
+	}
+	for _, tt := range tests {
+		col, got := Complete(tt.line)
+		if col != tt.col || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Complete(%q) = %d %q, want %d %q", tt.line, col, got, tt.col, tt.want)
+		}
+	}
+}
+
+// What Quote makes of s lexes back to s, without its variables expanded
+func TestQuote(t *testing.T) {
+	expand := func(name string) (string, bool) { return "expanded", true }
+	for _, s := range []string{"plain", "my file", "a\tb", `it's "x"`, `a\b`, "a|b>c;d", "$HOME ${x}", "é ü"} {
+		tokens, err := lex(Quote(s), expand)
+		if err != nil || len(tokens) != 1 || tokens[0].Val != s {
+			t.Errorf("Quote(%q) = %q lexes to %+v %v", s, Quote(s), tokens, err)
+		}
+	}
+}
+
+func TestCompleteJobs(t *testing.T) {
+	j := Start(&Buffer{}, "ca -n 1000 -every 1s x", Cmdhist{})
+	defer Idle()
+	defer j.Cancel()
+	id := strconv.Itoa(j.Id)
+	col, got := Complete("kill ")
+	if col != 6 || !reflect.DeepEqual(got, []string{id}) {
+		t.Errorf("Complete(kill ) = %d %q, want 6 [%s]", col, got, id)
+	}
+}
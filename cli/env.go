@@ -0,0 +1,139 @@
+// What commands run with, they write to a Sink rather than straight to the views
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Sink - Where output goes, the gocui views (screen.Sink) are one, Buffer keeps it in memory
+// and Writers sends it to io.Writers
+type Sink interface {
+	MsgWriter() io.Writer    // Messages, the msg view
+	ErrWriter() io.Writer    // Errors, the err view
+	PacketWriter() io.Writer // Packet traces, the packet view
+}
+
+// Bufferer - A Sink that can give back what is in a view e.g. "cmd"
+type Bufferer interface {
+	Buffer(view string) (string, error)
+}
+
+// Clearer - A Sink that can empty a view
+type Clearer interface {
+	Clear(view string) error
+}
+
+// Env - What a command runs with.
+// It is the context.Context that is cancelled when the command is interrupted,
+// long running commands should watch Done() and return Err() when it is.
+type Env struct {
+	context.Context
+	Sink           // Writers for the msg, err and packet output
+	Args []string  // Full name of the command (e.g. "packet show") then its arguments, less any declared flags
+	Hist Cmdhist   // Command history and prompt
+	In   io.Reader // For filters the output of the previous stage of the pipeline, nil for commands
+	Out  io.Writer // Output, the msg view, the next stage of a pipeline or a file
+	Job  *Job      // Job it is running as, nil if it is not
+
+	flags map[string]interface{} // Values of the flags the command declares, see Bool, Int...
+}
+
+// Buffer - Sink keeping the output in memory, e.g. to test commands or for a
+// front end that shows it itself
+type Buffer struct {
+	mu   sync.Mutex
+	bufs map[string]*bytes.Buffer
+}
+
+// bufWriter - Writes to one of the Buffer's views
+type bufWriter struct {
+	b    *Buffer
+	view string
+}
+
+func (w bufWriter) Write(p []byte) (int, error) {
+	w.b.mu.Lock()
+	defer w.b.mu.Unlock()
+	if w.b.bufs == nil {
+		w.b.bufs = make(map[string]*bytes.Buffer)
+	}
+	if w.b.bufs[w.view] == nil {
+		w.b.bufs[w.view] = &bytes.Buffer{}
+	}
+	return w.b.bufs[w.view].Write(p)
+}
+
+// MsgWriter - Writes to the msg buffer
+func (b *Buffer) MsgWriter() io.Writer {
+	return bufWriter{b: b, view: "msg"}
+}
+
+// ErrWriter - Writes to the err buffer
+func (b *Buffer) ErrWriter() io.Writer {
+	return bufWriter{b: b, view: "err"}
+}
+
+// PacketWriter - Writes to the packet buffer
+func (b *Buffer) PacketWriter() io.Writer {
+	return bufWriter{b: b, view: "packet"}
+}
+
+// Buffer - Everything written to view (msg, err or packet) so far, colours included
+func (b *Buffer) Buffer(view string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if view != "msg" && view != "err" && view != "packet" {
+		return "", fmt.Errorf("no view %s", view)
+	}
+	if b.bufs[view] == nil {
+		return "", nil
+	}
+	return b.bufs[view].String(), nil
+}
+
+// Clear - Empty the buffer for view
+func (b *Buffer) Clear(view string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if view != "msg" && view != "err" && view != "packet" {
+		return fmt.Errorf("no view %s", view)
+	}
+	delete(b.bufs, view)
+	return nil
+}
+
+// Writers - Sink sending the output to writers e.g. stdout and stderr when there is no gui,
+// output for a nil writer is thrown away
+type Writers struct {
+	Msg    io.Writer
+	Err    io.Writer
+	Packet io.Writer
+}
+
+// Nowhere for nil
+func orDiscard(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
+
+// MsgWriter - Writes to Msg
+func (w *Writers) MsgWriter() io.Writer {
+	return orDiscard(w.Msg)
+}
+
+// ErrWriter - Writes to Err
+func (w *Writers) ErrWriter() io.Writer {
+	return orDiscard(w.Err)
+}
+
+// PacketWriter - Writes to Packet
+func (w *Writers) PacketWriter() io.Writer {
+	return orDiscard(w.Packet)
+}
@@ -0,0 +1,94 @@
+// History expansion of !!, !n, !prefix and ^old^new
+
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Characters that end a !prefix
+const histend = " \t|>;'\"\\"
+
+// Find the history entry a ! refers to, event is what followed the !
+func (h Cmdhist) event(event string) (string, error) {
+	if event == "!" {
+		if len(h.Commands) == 0 {
+			return "", fmt.Errorf("!!: event not found")
+		}
+		return h.Commands[len(h.Commands)-1], nil
+	}
+	if n, err := strconv.Atoi(event); err == nil {
+		if n < 0 || n >= len(h.Commands) {
+			return "", fmt.Errorf("!%s: event not found", event)
+		}
+		return h.Commands[n], nil
+	}
+	for i := len(h.Commands) - 1; i >= 0; i-- {
+		if strings.HasPrefix(h.Commands[i], event) {
+			return h.Commands[i], nil
+		}
+	}
+	return "", fmt.Errorf("!%s: event not found", event)
+}
+
+// Expand - Replace history references in the line with the commands they refer to.
+// !! is the last command, !n command n as ls numbers them, !prefix the last command
+// starting with prefix and a line ^old^new is the last command with old changed to new.
+// Nothing is expanded inside 'single quotes' or after a \.
+func (h Cmdhist) Expand(line string) (string, error) {
+	if strings.HasPrefix(line, "^") {
+		parts := strings.SplitN(line[1:], "^", 3)
+		if len(parts) < 2 || parts[0] == "" {
+			return "", fmt.Errorf("%s: bad substitution", line)
+		}
+		last, err := h.event("!")
+		if err != nil {
+			return "", err
+		}
+		if !strings.Contains(last, parts[0]) {
+			return "", fmt.Errorf("%s: %s not found in %s", line, parts[0], last)
+		}
+		s := strings.Replace(last, parts[0], parts[1], 1)
+		if len(parts) == 3 {
+			s += parts[2]
+		}
+		return s, nil
+	}
+
+	var expanded strings.Builder
+	var quoted bool
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case quoted:
+		case r == '\\' && i+1 < len(runes):
+			expanded.WriteRune(r)
+			i++
+			r = runes[i]
+		case r == '!' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) && !strings.ContainsRune(histend, runes[i+1]):
+			// Work out which event, !! or everything up to a space or special character
+			end := i + 2
+			if runes[i+1] != '!' {
+				for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(histend, runes[end]) {
+					end++
+				}
+			}
+			s, err := h.event(string(runes[i+1 : end]))
+			if err != nil {
+				return "", err
+			}
+			expanded.WriteString(s)
+			i = end - 1
+			continue
+		}
+		expanded.WriteRune(r)
+	}
+	return expanded.String(), nil
+}
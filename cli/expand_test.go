@@ -0,0 +1,60 @@
+package cli
+
+import "testing"
+
+func TestExpand(t *testing.T) {
+	h := Cmdhist{Commands: []string{"ls", "help | head 2", "ca -n 3 x", "echo one two"}}
+	tests := []struct {
+		line string
+		want string
+		err  string
+	}{
+		{line: "ls -l", want: "ls -l"},
+		{line: "", want: ""},
+		// !!, !n and !prefix
+		{line: "!!", want: "echo one two"},
+		{line: "!! | head 1", want: "echo one two | head 1"},
+		{line: "!!!!", want: "echo one twoecho one two"},
+		{line: "!0", want: "ls"},
+		{line: "!1 | wc", want: "help | head 2 | wc"},
+		{line: "!3;!0", want: "echo one two;ls"},
+		{line: "!3; ls", want: "echo one two; ls"},
+		{line: "!h", want: "help | head 2"},
+		{line: "!ca|sort", want: "ca -n 3 x|sort"},
+		{line: "!e>f", want: "echo one two>f"},
+		{line: "x !l y", want: "x ls y"},
+		{line: "!4", err: "!4: event not found"},
+		{line: "!-1", err: "!-1: event not found"},
+		{line: "!nosuch", err: "!nosuch: event not found"},
+		// Left alone
+		{line: "echo !", want: "echo !"},
+		{line: "echo ! x", want: "echo ! x"},
+		{line: "echo !'x'", want: "echo !'x'"},
+		{line: "echo '!!' \\!! a\\!", want: "echo '!!' \\!! a\\!"},
+		{line: "echo '!!' !!", want: "echo '!!' echo one two"},
+		// ^old^new
+		{line: "^one^three", want: "echo three two"},
+		{line: "^one^three^ | wc", want: "echo three two | wc"},
+		{line: "^one^", want: "echo  two"},
+		{line: "^o^0", want: "ech0 one two"},
+		{line: "^", err: "^: bad substitution"},
+		{line: "^^x", err: "^^x: bad substitution"},
+		{line: "^nosuch^x", err: "^nosuch^x: nosuch not found in echo one two"},
+	}
+	for _, tt := range tests {
+		got, err := h.Expand(tt.line)
+		switch {
+		case tt.err != "" && (err == nil || err.Error() != tt.err):
+			t.Errorf("Expand(%q) error %v, want %q", tt.line, err, tt.err)
+		case tt.err == "" && err != nil:
+			t.Errorf("Expand(%q): %s", tt.line, err)
+		case got != tt.want:
+			t.Errorf("Expand(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+	for _, line := range []string{"!!", "^a^b"} {
+		if _, err := (Cmdhist{}).Expand(line); err == nil {
+			t.Errorf("Expand(%q) with no history is not an error", line)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Flags and positional arguments a command declares, checked and parsed before it runs
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charlesetsmith/testgocui/screen"
+)
+
+// FlagType - What kind of value a flag has
+type FlagType int
+
+// The flag types
+const (
+	Bool     FlagType = iota // -name, or -name=false
+	Int                      // -name 5
+	Duration                 // -name 1m30s
+	String                   // -name text
+	Enum                     // -name one of Values
+	Colour                   // -name a colour spec or style e.g. bold:red/black or error
+)
+
+// Flag - An option of a command, given as -name value or -name=value
+type Flag struct {
+	Name    string // Without the -
+	Type    FlagType
+	Default string   // Value when it is not given, "" is false, 0 or "" for the type
+	Values  []string // What an Enum can be
+	Help    string
+}
+
+// Positional - The arguments after the flags, from Min to Max of them (-1 for any number)
+type Positional struct {
+	Name string // For the usage e.g. "file" is shown as <file>
+	Min  int
+	Max  int
+}
+
+// UsageError - The arguments do not match what the command declared
+type UsageError struct {
+	Cmd *Cmd
+	Msg string
+}
+
+func (e *UsageError) Error() string {
+	return fmt.Sprintf("%s: %s\nusage: %s", e.Cmd.path, e.Msg, e.Cmd.Usage)
+}
+
+// Parse the value s for the flag
+func (f *Flag) parse(s string) (interface{}, error) {
+	switch f.Type {
+	case Bool:
+		if s == "" {
+			return false, nil
+		}
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+		return nil, errors.New("want true or false")
+	case Int:
+		if s == "" {
+			return 0, nil
+		}
+		if n, err := strconv.Atoi(s); err == nil {
+			return n, nil
+		}
+		return nil, errors.New("want a whole number")
+	case Duration:
+		if s == "" {
+			return time.Duration(0), nil
+		}
+		if d, err := time.ParseDuration(s); err == nil {
+			return d, nil
+		}
+		return nil, errors.New("want a duration e.g. 1m30s")
+	case Enum:
+		if !contains(f.Values, s) {
+			return nil, fmt.Errorf("want one of %s", strings.Join(f.Values, ", "))
+		}
+		return s, nil
+	case Colour:
+		if err := screen.ValidColour(s); err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return s, nil
+	}
+}
+
+// How the flag is shown in the usage e.g. [-n int]
+func (f *Flag) usage() string {
+	switch f.Type {
+	case Bool:
+		return "[-" + f.Name + "]"
+	case Int:
+		return "[-" + f.Name + " n]"
+	case Duration:
+		return "[-" + f.Name + " duration]"
+	case Enum:
+		return "[-" + f.Name + " " + strings.Join(f.Values, "|") + "]"
+	case Colour:
+		return "[-" + f.Name + " colour]"
+	default:
+		return "[-" + f.Name + " text]"
+	}
+}
+
+// How the positional arguments are shown in the usage e.g. <file> [file]...
+func (p *Positional) usage() string {
+	var words []string
+
+	for i := 0; i < p.Min; i++ {
+		words = append(words, "<"+p.Name+">")
+	}
+	switch {
+	case p.Max < 0 && p.Min > 0:
+		words[len(words)-1] += "..."
+	case p.Max < 0:
+		words = append(words, "["+p.Name+"]...")
+	default:
+		for i := p.Min; i < p.Max; i++ {
+			words = append(words, "["+p.Name+"]")
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Check the flags and positional arguments the command declares
+func (c *Cmd) checkflags() {
+	for i, f := range c.Flags {
+		if f.Name == "" || strings.ContainsAny(f.Name, " \t=") || strings.HasPrefix(f.Name, "-") {
+			log.Panicf("cli.Register: command %s has invalid flag name %q", c.path, f.Name)
+		}
+		for _, g := range c.Flags[:i] {
+			if g.Name == f.Name {
+				log.Panicf("cli.Register: command %s has flag -%s twice", c.path, f.Name)
+			}
+		}
+		if f.Type == Enum && len(f.Values) == 0 {
+			log.Panicf("cli.Register: command %s flag -%s has no Values", c.path, f.Name)
+		}
+		if f.Type == Enum && f.Default == "" {
+			c.Flags[i].Default = f.Values[0]
+		}
+		if _, err := c.Flags[i].parse(c.Flags[i].Default); err != nil {
+			log.Panicf("cli.Register: command %s flag -%s default %q: %s", c.path, f.Name, f.Default, err)
+		}
+	}
+	if p := c.Positional; p != nil && (p.Min < 0 || (p.Max >= 0 && p.Max < p.Min) || (p.Max != 0 && p.Name == "")) {
+		log.Panicf("cli.Register: command %s has invalid Positional %+v", c.path, *p)
+	}
+}
+
+// The declared flag called name
+func (c *Cmd) flag(name string) *Flag {
+	for i := range c.Flags {
+		if c.Flags[i].Name == name {
+			return &c.Flags[i]
+		}
+	}
+	return nil
+}
+
+// The declared flags as they are typed e.g. -n
+func (c *Cmd) flagnames() []string {
+	names := make([]string, len(c.Flags))
+	for i, f := range c.Flags {
+		names[i] = "-" + f.Name
+	}
+	return names
+}
+
+// Parse the arguments (after the command name) into the flags and the positional arguments.
+// Flags can come anywhere before a --, anything else (or a negative number) is positional.
+func (c *Cmd) parseargs(args []string) (map[string]interface{}, []string, error) {
+	var positional []string
+
+	flags := make(map[string]interface{}, len(c.Flags))
+	for i := range c.Flags {
+		flags[c.Flags[i].Name], _ = c.Flags[i].parse(c.Flags[i].Default)
+	}
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" && len(c.Flags) > 0 {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if len(a) < 2 || a[0] != '-' || len(c.Flags) == 0 {
+			positional = append(positional, a)
+			continue
+		}
+		name, value, hasvalue := strings.Cut(a[1:], "=")
+		f := c.flag(name)
+		if f == nil {
+			if _, err := strconv.ParseFloat(a, 64); err == nil { // A negative number
+				positional = append(positional, a)
+				continue
+			}
+			return nil, nil, &UsageError{Cmd: c, Msg: "unknown flag " + a}
+		}
+		switch {
+		case hasvalue:
+		case f.Type == Bool:
+			value = "true"
+		case i+1 < len(args):
+			i++
+			value = args[i]
+		default:
+			return nil, nil, &UsageError{Cmd: c, Msg: "missing value for " + a}
+		}
+		v, err := f.parse(value)
+		if err != nil {
+			return nil, nil, &UsageError{Cmd: c, Msg: fmt.Sprintf("invalid value %q for -%s, %s", value, name, err)}
+		}
+		flags[name] = v
+	}
+	if p := c.Positional; p != nil {
+		switch {
+		case len(positional) < p.Min:
+			return nil, nil, &UsageError{Cmd: c, Msg: "missing " + p.Name}
+		case p.Max >= 0 && len(positional) > p.Max:
+			return nil, nil, &UsageError{Cmd: c, Msg: "too many arguments"}
+		}
+	}
+	return flags, positional, nil
+}
+
+// The value of a declared flag, panics if the command does not declare it
+func (e *Env) flag(name string) interface{} {
+	v, ok := e.flags[name]
+	if !ok {
+		log.Panicf("cli: %s has no flag -%s", e.Args[0], name)
+	}
+	return v
+}
+
+// Bool - Value of the Bool flag name
+func (e *Env) Bool(name string) bool {
+	v, _ := e.flag(name).(bool)
+	return v
+}
+
+// Int - Value of the Int flag name
+func (e *Env) Int(name string) int {
+	v, _ := e.flag(name).(int)
+	return v
+}
+
+// Duration - Value of the Duration flag name
+func (e *Env) Duration(name string) time.Duration {
+	v, _ := e.flag(name).(time.Duration)
+	return v
+}
+
+// Flag - Value of the String or Enum flag name
+func (e *Env) Flag(name string) string {
+	v, _ := e.flag(name).(string)
+	return v
+}
@@ -0,0 +1,18 @@
+package cli
+
+import "testing"
+
+func TestFlags(t *testing.T) {
+	testDocmd(t, []docmdTest{
+		{cmd: "ca -n 2 -prefix p -- -x", msg: "p[ca -x]\np[ca -x]\n"},
+		{cmd: "ca -n=1 -prefix= a", msg: "[ca a]\n"},
+		// Usage errors
+		{cmd: "kill", err: "kill: missing n\nusage: kill <n>...", failed: true},
+		{cmd: "unalias", err: "unalias: missing name\nusage: unalias <name>...", failed: true},
+		{cmd: "ca -nosuch", err: "ca: unknown flag -nosuch\nusage: ca", failed: true},
+		{cmd: "ca -n", err: "ca: missing value for -n\nusage: ca", failed: true},
+		{cmd: "ca -n x", err: `ca: invalid value "x" for -n, want a whole number`, failed: true},
+		{cmd: "ca -every 1x a", err: `invalid value "1x" for -every, want a duration`, failed: true},
+		{cmd: "ca -colour nosuch x", err: "usage: ca", failed: true},
+	})
+}
@@ -0,0 +1,175 @@
+// Help for the commands, their flags and the keys of the front end
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charlesetsmith/testgocui/screen"
+)
+
+// KeyHelp - A key, or the keys that do the same thing, and what it does
+type KeyHelp struct {
+	Keys string // e.g. "CtrlP" or "Up/Down"
+	Help string
+}
+
+func (k KeyHelp) String() string {
+	return k.Keys + " - " + k.Help
+}
+
+// Keys - What the keys of the front end do for help, made from its key bindings
+var Keys []KeyHelp
+
+// How command lines are put together
+var syntax = []KeyHelp{
+	{"cmd | filter [arg]... | ...", "Pass the output of cmd through filters"},
+	{"cmd > file, cmd >> file", "Write or append the output of cmd to file"},
+	{"cmd; cmd", "Run the commands one after the other"},
+	{"$name, ${name}, $?", "The value of a variable, see vars"},
+}
+
+func init() {
+	Register(Cmd{Name: "help", Aliases: []string{"usage", "?"}, Usage: "help [command [subcommand]...]",
+		Help:     "List of available commands, or the help for one and its subcommands",
+		Long:     "With a command shows all about it, its flags, examples and subcommands.",
+		Examples: []string{"help", "help grep", "help packet save"},
+		Fn:       usage, Complete: CompleteCommands, Category: "General"})
+}
+
+// Show the title then the lines under it
+func helpsection(w io.Writer, title string, lines string) {
+	if lines == "" {
+		return
+	}
+	screen.Fprintf(w, "title", "%s:\n", title)
+	screen.Fprintf(w, "output", "%s", lines)
+}
+
+// The one line usage and help of c then its subcommands indented under it
+func helptree(c *Cmd, indent string) string {
+	s := fmt.Sprintf("%s%s: %s", indent, c.Usage, c.Help)
+	if len(c.Aliases) > 0 {
+		s += fmt.Sprintf(" (also %s)", strings.Join(c.Aliases, ", "))
+	}
+	s += "\n"
+	for _, n := range c.SubNames() {
+		s += helptree(c.Sub(n), indent+"  ")
+	}
+	return s
+}
+
+// Table of the flags of c, what they do and their defaults
+func flagtable(c *Cmd) string {
+	var s strings.Builder
+
+	tw := tabwriter.NewWriter(&s, 0, 8, 2, ' ', 0)
+	for _, f := range c.Flags {
+		help := f.Help
+		if f.Default != "" && !(f.Type == Bool && f.Default == "false") {
+			help += fmt.Sprintf(" (default %s)", f.Default)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", strings.Trim(f.usage(), "[]"), help)
+	}
+	tw.Flush()
+	return s.String()
+}
+
+// help <command> [subcommand]... - All about the command, its flags, examples and subcommands
+func cmdhelp(e *Env) error {
+	if value, ok := LookupAlias(e.Args[1]); ok && len(e.Args) == 2 {
+		screen.Fprintf(e.Out, "output", "%s: alias for %s\n", e.Args[1], value)
+		return nil
+	}
+	c, n := Find(e.Args[1:])
+	switch {
+	case c == nil:
+		ierr := invalid(e.Args[1], 0, nil)
+		if len(ierr.Ambiguous) > 0 {
+			return ierr
+		}
+		return fmt.Errorf("no command %s%s", e.Args[1], ierr.hint())
+	case n < len(e.Args)-1 && len(c.subs) == 0:
+		return fmt.Errorf("%s has no subcommands", c.path)
+	case n < len(e.Args)-1:
+		return invalid(e.Args[n+1], 0, c)
+	}
+	s := fmt.Sprintf("usage: %s\n%s\n", c.Usage, c.Help)
+	if c.Long != "" {
+		s += c.Long + "\n"
+	}
+	if len(c.Aliases) > 0 {
+		s += fmt.Sprintf("Also %s\n", strings.Join(c.Aliases, ", "))
+	}
+	screen.Fprintf(e.Out, "output", "%s", s)
+	helpsection(e.Out, "Flags", flagtable(c))
+	var examples, subs string
+	for _, x := range c.Examples {
+		examples += "  " + x + "\n"
+	}
+	helpsection(e.Out, "Examples", examples)
+	for _, n := range c.SubNames() {
+		subs += helptree(c.Sub(n), "  ")
+	}
+	helpsection(e.Out, "Subcommands", subs)
+	return nil
+}
+
+// usage - The keys, then the commands by category and the aliases
+func usage(e *Env) error {
+	var keys, lines string
+
+	if len(e.Args) > 1 {
+		return cmdhelp(e)
+	}
+	for _, k := range Keys {
+		keys += "  " + k.String() + "\n"
+	}
+	helpsection(e.Out, "Keys", keys)
+	for _, k := range syntax {
+		lines += "  " + k.String() + "\n"
+	}
+	helpsection(e.Out, "Command lines", lines)
+
+	bycategory := map[string][]*Cmd{}
+	regMu.RLock()
+	for _, c := range Commands {
+		category := c.Category
+		if category == "" {
+			category = "Other"
+		}
+		bycategory[category] = append(bycategory[category], c)
+	}
+	regMu.RUnlock()
+	categories := make([]string, 0, len(bycategory))
+	for category := range bycategory {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		cmds := bycategory[category]
+		sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+		lines = ""
+		for _, c := range cmds {
+			lines += fmt.Sprintf("  %s: %s", c.Usage, c.Help)
+			if len(c.Aliases) > 0 {
+				lines += fmt.Sprintf(" (also %s)", strings.Join(c.Aliases, ", "))
+			}
+			lines += "\n"
+		}
+		helpsection(e.Out, category, lines)
+	}
+	lines = ""
+	for _, a := range AliasNames() {
+		if value, ok := LookupAlias(a); ok {
+			lines += fmt.Sprintf("  %s: alias for %s\n", a, value)
+		}
+	}
+	helpsection(e.Out, "Your aliases", lines)
+	screen.Fprintln(e.Out, "output", "help <command> for more about one")
+	return nil
+}
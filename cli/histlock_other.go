@@ -0,0 +1,8 @@
+//go:build !unix
+
+package cli
+
+// No file locking here, sessions appending at the same time may interleave
+func lockhistory(file string) (func(), error) {
+	return func() {}, nil
+}
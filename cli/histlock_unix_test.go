@@ -0,0 +1,35 @@
+//go:build unix
+
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+// Add waits while another session has the history locked
+func TestHistoryLock(t *testing.T) {
+	testhistory(t, 3)
+	unlock, err := lockhistory(Histfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added := make(chan error)
+	go func() {
+		var h Cmdhist
+		added <- h.Add("a")
+	}()
+	select {
+	case err := <-added:
+		unlock()
+		t.Fatalf("Add did not wait for the lock: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	if err := <-added; err != nil {
+		t.Fatal(err)
+	}
+	if got := histlines(t); len(got) != 1 || got[0] != "a" {
+		t.Errorf("%s has %q", Histfile, got)
+	}
+}
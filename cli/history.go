@@ -0,0 +1,145 @@
+// Command history kept in a file between sessions
+
+package cli
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Histfile - File the command history is kept in between sessions, "" not to keep it.
+// Defaults to $XDG_STATE_HOME/testgocui/history (~/.local/state/testgocui/history).
+var Histfile = defaulthistfile()
+
+// Histsize - Most commands kept in history and in Histfile
+var Histsize = 1000
+
+func defaulthistfile() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "testgocui", "history")
+}
+
+// Drop consecutive duplicates and keep only the last Histsize commands
+func tidyhistory(cmds []string) []string {
+	var tidy []string
+
+	for _, c := range cmds {
+		if c == "" || (len(tidy) > 0 && tidy[len(tidy)-1] == c) {
+			continue
+		}
+		tidy = append(tidy, c)
+	}
+	if Histsize >= 0 && len(tidy) > Histsize {
+		tidy = tidy[len(tidy)-Histsize:]
+	}
+	return tidy
+}
+
+// Histfile is trimmed back to Histsize commands once it has histslack times that many,
+// so it is not rewritten every time a command is added
+const histslack = 2
+
+// The commands in Histfile. The caller holds the lock.
+func readhistory() ([]string, error) {
+	var lines []string
+
+	f, err := os.Open(Histfile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// Replace Histfile with cmds, via a temporary file so nothing is lost if we fail. The caller holds the lock.
+func writehistory(cmds []string) error {
+	var s strings.Builder
+
+	for _, c := range cmds {
+		s.WriteString(c + "\n")
+	}
+	tmp := Histfile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(s.String()), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, Histfile)
+}
+
+// LoadHistory - Read the history from Histfile into Commands.
+// The file is trimmed to Histsize commands if other sessions have made it grow past that.
+func (h *Cmdhist) LoadHistory() error {
+	if Histfile == "" {
+		return nil
+	}
+	unlock, err := lockhistory(Histfile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	lines, err := readhistory()
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	h.Commands = tidyhistory(append(lines, h.Commands...))
+	if len(h.Commands) == len(lines) {
+		return nil
+	}
+	// Write it back tidied up
+	return writehistory(h.Commands)
+}
+
+// Add - Add the command to the history, and append it to Histfile.
+// Repeats of the last command and empty commands are not added.
+func (h *Cmdhist) Add(cmd string) error {
+	if cmd == "" || (len(h.Commands) > 0 && h.Commands[len(h.Commands)-1] == cmd) {
+		return nil
+	}
+	h.Commands = append(h.Commands, cmd)
+	if Histsize >= 0 && len(h.Commands) > Histsize {
+		h.Commands = h.Commands[len(h.Commands)-Histsize:]
+	}
+	if Histfile == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(Histfile), 0700); err != nil {
+		return err
+	}
+	// Other sessions may be appending to it as well
+	unlock, err := lockhistory(Histfile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f, err := os.OpenFile(Histfile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteString(cmd + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil || Histsize < 0 {
+		return err
+	}
+	lines, err := readhistory()
+	if err != nil || len(lines) <= histslack*Histsize {
+		return err
+	}
+	return writehistory(tidyhistory(lines))
+}
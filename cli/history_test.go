@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Keep the history in a file of its own, with at most size commands
+func testhistory(t *testing.T, size int) {
+	file, histsize := Histfile, Histsize
+	t.Cleanup(func() { Histfile, Histsize = file, histsize })
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	Histfile, Histsize = defaulthistfile(), size
+}
+
+// The commands in Histfile
+func histlines(t *testing.T) []string {
+	t.Helper()
+	b, err := os.ReadFile(Histfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func TestDefaultHistfile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", dir)
+	if got, want := defaulthistfile(), filepath.Join(dir, "testgocui", "history"); got != want {
+		t.Errorf("with XDG_STATE_HOME %s, want %s", got, want)
+	}
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("HOME", dir)
+	if got, want := defaulthistfile(), filepath.Join(dir, ".local", "state", "testgocui", "history"); got != want {
+		t.Errorf("without XDG_STATE_HOME %s, want %s", got, want)
+	}
+}
+
+func TestHistoryAdd(t *testing.T) {
+	testhistory(t, 3)
+	var h Cmdhist
+	for _, c := range []string{"a", "a", "", "b", "c", "d"} {
+		if err := h.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"b", "c", "d"}; !reflect.DeepEqual(h.Commands, want) {
+		t.Errorf("Commands %q, want %q", h.Commands, want)
+	}
+	// The file has all of them until it has twice Histsize
+	if got, want := histlines(t), []string{"a", "b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("%s has %q, want %q", Histfile, got, want)
+	}
+	for _, c := range []string{"e", "f", "g"} {
+		if err := h.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := histlines(t), []string{"e", "f", "g"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("%s has %q once trimmed, want %q", Histfile, got, want)
+	}
+}
+
+func TestLoadHistory(t *testing.T) {
+	testhistory(t, 3)
+	h := Cmdhist{Commands: []string{"x"}}
+	if err := h.LoadHistory(); err != nil || len(h.Commands) != 1 {
+		t.Fatalf("with no file %q %v", h.Commands, err)
+	}
+	if _, err := os.Stat(Histfile); !os.IsNotExist(err) {
+		t.Errorf("%s made by loading it: %v", Histfile, err)
+	}
+	// Another session's commands, tidied up and trimmed with ours
+	if err := os.MkdirAll(filepath.Dir(Histfile), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(Histfile, []byte("a\nb\nb\n\nc\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.LoadHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "c", "x"}; !reflect.DeepEqual(h.Commands, want) {
+		t.Errorf("Commands %q, want %q", h.Commands, want)
+	}
+	if got := histlines(t); !reflect.DeepEqual(got, h.Commands) {
+		t.Errorf("%s has %q, want %q", Histfile, got, h.Commands)
+	}
+}
+
+func TestNoHistfile(t *testing.T) {
+	testhistory(t, 3)
+	Histfile = ""
+	var h Cmdhist
+	if err := h.Add("a"); err != nil || len(h.Commands) != 1 {
+		t.Errorf("Add %q %v", h.Commands, err)
+	}
+	if err := h.LoadHistory(); err != nil || len(h.Commands) != 1 {
+		t.Errorf("LoadHistory %q %v", h.Commands, err)
+	}
+}
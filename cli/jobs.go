@@ -0,0 +1,270 @@
+// Job control for commands entered at the prompt
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charlesetsmith/testgocui/screen"
+)
+
+// Job - A command line running in its own go routine
+type Job struct {
+	Id      int    // Prompt line number (Cmdhist.Curline) the command was entered on
+	Cmdline string // What was entered
+	Started time.Time
+	cancel  context.CancelFunc
+	done    chan struct{}
+	mu      sync.Mutex
+	err     error
+}
+
+// Done - Closed when the job has finished
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Err - Result of the job, only valid once it is Done
+func (j *Job) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+// State - Running, Done, Interrupted or Failed
+func (j *Job) State() string {
+	select {
+	case <-j.done:
+	default:
+		return "Running"
+	}
+	var xerr *ExitError
+	switch err := j.Err(); {
+	case err == nil, errors.As(err, &xerr):
+		return "Done"
+	case errors.Is(err, context.Canceled):
+		return "Interrupted"
+	default:
+		return "Failed"
+	}
+}
+
+// Cancel - Interrupt the job
+func (j *Job) Cancel() {
+	j.cancel()
+}
+
+// Running jobs, the last one is in the foreground and is what Interrupt cancels
+var jobsMu sync.Mutex
+var jobs []*Job
+
+// Jobs started that have not yet printed they are finished
+var unfinished sync.WaitGroup
+
+// OnExit - Called when a job ran exit with its status (-1 if none), the front end sets it to quit
+var OnExit func(status int)
+
+type jobKey struct{}
+
+// JobFrom - The job a command is running as, nil if it is not running as a job
+func JobFrom(ctx context.Context) *Job {
+	j, _ := ctx.Value(jobKey{}).(*Job)
+	return j
+}
+
+// Jobs - Snapshot of the running jobs, foreground job last
+func Jobs() []*Job {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	return append([]*Job(nil), jobs...)
+}
+
+// FindJob - The running job entered on line id, nil if there is none
+func FindJob(id int) *Job {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	return findjob(id)
+}
+
+func findjob(id int) *Job {
+	for _, j := range jobs {
+		if j.Id == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// Start - Run the command line in its own go routine as the foreground job with its output to sink.
+// It is keyed by cmds.Curline, the line number of the prompt it was entered on.
+func Start(sink Sink, s string, cmds Cmdhist) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &Job{Id: cmds.Curline, Cmdline: s, Started: time.Now(), cancel: cancel, done: make(chan struct{})}
+	ctx = context.WithValue(ctx, jobKey{}, j)
+
+	jobsMu.Lock()
+	jobs = append(jobs, j)
+	jobsMu.Unlock()
+	screen.Fprintf(sink.MsgWriter(), "info", "[%d] Started %s\n", j.Id, j.Cmdline)
+	unfinished.Add(1)
+	go func() {
+		defer unfinished.Done()
+		err := Docmd(ctx, sink, s, cmds)
+		cancel()
+		j.mu.Lock()
+		j.err = err
+		j.mu.Unlock()
+		jobsMu.Lock()
+		for i := range jobs {
+			if jobs[i] == j {
+				jobs = append(jobs[:i], jobs[i+1:]...)
+				break
+			}
+		}
+		jobsMu.Unlock()
+		close(j.done)
+		screen.Fprintf(sink.MsgWriter(), "info", "[%d] %s %s\n", j.Id, j.State(), j.Cmdline)
+		var xerr *ExitError
+		if errors.As(err, &xerr) && OnExit != nil {
+			OnExit(xerr.Status)
+		}
+	}()
+	return j
+}
+
+// Idle - Wait until every job started has finished and printed that it has
+func Idle() {
+	unfinished.Wait()
+}
+
+// Interrupt - Cancel the foreground job, false if none are running
+func Interrupt() bool {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	if len(jobs) == 0 {
+		return false
+	}
+	jobs[len(jobs)-1].Cancel()
+	return true
+}
+
+// CancelAll - Cancel every running job, used when we quit
+func CancelAll() {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	for _, j := range jobs {
+		j.Cancel()
+	}
+}
+
+// Get the job numbers from the command arguments
+func jobids(args []string) ([]int, error) {
+	var ids []int
+
+	for _, a := range args {
+		id, err := strconv.Atoi(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid job number %s", a)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// jobs - List the running jobs
+func jobscmd(e *Env) error {
+	var s string
+
+	for _, j := range Jobs() {
+		if j == e.Job {
+			continue
+		}
+		s += fmt.Sprintf("[%d] %s %s %s\n", j.Id, j.State(),
+			time.Since(j.Started).Round(time.Second), j.Cmdline)
+	}
+	if s == "" {
+		s = "No jobs running\n"
+	}
+	screen.Fprintf(e.Out, "output", "%s", s)
+	return nil
+}
+
+// kill <n>... - Interrupt jobs
+func kill(e *Env) error {
+	ids, err := jobids(e.Args[1:])
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		j := FindJob(id)
+		if j == nil {
+			return fmt.Errorf("no job %d running", id)
+		}
+		j.Cancel()
+	}
+	return nil
+}
+
+// wait [n]... - Wait for jobs to finish, all other jobs if none are given
+func wait(e *Env) error {
+	var waiting []*Job
+
+	ids, err := jobids(e.Args[1:])
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		for _, j := range Jobs() {
+			if j != e.Job {
+				waiting = append(waiting, j)
+			}
+		}
+	}
+	for _, id := range ids {
+		j := FindJob(id)
+		if j == nil {
+			return fmt.Errorf("no job %d running", id)
+		}
+		if j == e.Job {
+			return errors.New("cannot wait for itself")
+		}
+		waiting = append(waiting, j)
+	}
+	for _, j := range waiting {
+		select {
+		case <-e.Done():
+			return e.Err()
+		case <-j.Done():
+		}
+	}
+	return nil
+}
+
+// fg <n> - Move a job to the foreground so CtrlC interrupts it
+func fg(e *Env) error {
+	ids, err := jobids(e.Args[1:])
+	if err != nil {
+		return err
+	}
+	jobsMu.Lock()
+	j := findjob(ids[0])
+	if j != nil {
+		for i := range jobs {
+			if jobs[i] == j {
+				jobs = append(append(jobs[:i], jobs[i+1:]...), j)
+				break
+			}
+		}
+	}
+	jobsMu.Unlock()
+	if j == nil {
+		return fmt.Errorf("no job %d running", ids[0])
+	}
+	screen.Fprintf(e.Out, "output", "[%d] %s\n", j.Id, j.Cmdline)
+	return nil
+}
@@ -0,0 +1,201 @@
+// Shell style splitting of the command line into words
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Token - A word from the command line and the column it starts in
+type Token struct {
+	Val string
+	Col int  // Column of the first character of the word, starting at 1
+	Op  bool // An unquoted operator |, >, >> or ; rather than a word
+}
+
+// SyntaxError - Problem found splitting the command line
+type SyntaxError struct {
+	Col int // Column it was found at, starting at 1
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Col, e.Msg)
+}
+
+// Lex - Split the command line into words and operators.
+// Words are separated by white space or an operator (| > >> ;), 'single quotes' keep everything as is,
+// "double quotes" allow \" \\ \$ escapes and outside quotes \ escapes the next character.
+// A $ is kept as it is, Run expands the variables.
+func Lex(s string) ([]Token, error) {
+	return lex(s, nil)
+}
+
+// The variable name after the $ at runes[i] and the index of its last rune,
+// "" if it is not followed by a name and so is just a $
+func varref(runes []rune, i int) (string, int, error) {
+	switch {
+	case i+1 == len(runes):
+		return "", i, nil
+	case runes[i+1] == '?':
+		return "?", i + 1, nil
+	case runes[i+1] == '{':
+		for j := i + 2; j < len(runes); j++ {
+			if runes[j] == '}' {
+				name := string(runes[i+2 : j])
+				if _, ok := Builtins[name]; !ok && !varname(name) {
+					return "", i, &SyntaxError{Col: i + 1, Msg: fmt.Sprintf("invalid variable name %q", name)}
+				}
+				return name, j, nil
+			}
+		}
+		return "", i, &SyntaxError{Col: i + 1, Msg: "missing } after ${"}
+	}
+	j := i + 1
+	for j < len(runes) && (runes[j] == '_' || unicode.IsLetter(runes[j]) || (j > i+1 && unicode.IsDigit(runes[j]))) {
+		j++
+	}
+	return string(runes[i+1 : j]), j - 1, nil
+}
+
+// Lex expanding $name, ${name} and $? outside single quotes with the values from lookup (if set),
+// the value becomes part of the word rather than being split into words
+func lex(s string, lookup func(name string) (string, bool)) ([]Token, error) {
+	var tokens []Token
+	var word strings.Builder
+	var inword bool  // Are we in a word, "" is still a word
+	var quote rune   // The quote we are inside of or 0
+	var quotecol int // Where that quote started
+	var start int    // Where the current word started
+
+	runes := []rune(s)
+	// Replace the $name at runes[i] with its value, false if it is just a $
+	expand := func(i *int) (bool, error) {
+		if lookup == nil {
+			return false, nil
+		}
+		name, end, err := varref(runes, *i)
+		if err != nil || name == "" {
+			return false, err
+		}
+		value, ok := lookup(name)
+		if !ok {
+			return false, &SyntaxError{Col: *i + 1, Msg: "undefined variable $" + name}
+		}
+		word.WriteString(value)
+		*i = end
+		return true, nil
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		col := i + 1
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				if i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\' || runes[i+1] == '$') {
+					i++
+					word.WriteRune(runes[i])
+				} else {
+					word.WriteRune(r)
+				}
+			case '$':
+				if ok, err := expand(&i); err != nil {
+					return nil, err
+				} else if !ok {
+					word.WriteRune(r)
+				}
+			default:
+				word.WriteRune(r)
+			}
+		case unicode.IsSpace(r):
+			if inword {
+				tokens = append(tokens, Token{Val: word.String(), Col: start})
+				word.Reset()
+				inword = false
+			}
+		case r == '|' || r == '>' || r == ';':
+			if inword {
+				tokens = append(tokens, Token{Val: word.String(), Col: start})
+				word.Reset()
+				inword = false
+			}
+			op := string(r)
+			if r == '>' && i+1 < len(runes) && runes[i+1] == '>' {
+				op = ">>"
+				i++
+			}
+			tokens = append(tokens, Token{Val: op, Col: col, Op: true})
+		default:
+			if !inword {
+				inword = true
+				start = col
+			}
+			switch r {
+			case '\'', '"':
+				quote = r
+				quotecol = col
+			case '\\':
+				if i+1 == len(runes) {
+					return nil, &SyntaxError{Col: col, Msg: "backslash at end of line"}
+				}
+				i++
+				word.WriteRune(runes[i])
+			case '$':
+				if ok, err := expand(&i); err != nil {
+					return nil, err
+				} else if !ok {
+					word.WriteRune(r)
+				}
+			default:
+				word.WriteRune(r)
+			}
+		}
+	}
+	if quote != 0 {
+		return nil, &SyntaxError{Col: quotecol, Msg: fmt.Sprintf("unterminated %c quote", quote)}
+	}
+	if inword {
+		tokens = append(tokens, Token{Val: word.String(), Col: start})
+	}
+	return tokens, nil
+}
+
+// Words - Just the values of the tokens
+func Words(tokens []Token) []string {
+	words := make([]string, len(tokens))
+	for i, t := range tokens {
+		words[i] = t.Val
+	}
+	return words
+}
+
+// Split - Cut the command line into the commands separated by unquoted ;s
+func Split(s string) ([]string, error) {
+	var cmds []string
+
+	tokens, err := Lex(s)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	start := 0
+	for _, t := range tokens {
+		if t.Op && t.Val == ";" {
+			cmds = append(cmds, string(runes[start:t.Col-1]))
+			start = t.Col
+		}
+	}
+	return append(cmds, string(runes[start:])), nil
+}
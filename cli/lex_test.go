@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []Token
+	}{
+		{"", nil},
+		{" \t  ", nil},
+		{"ls", []Token{{Val: "ls", Col: 1}}},
+		{"  ca  -n 3 ", []Token{{Val: "ca", Col: 3}, {Val: "-n", Col: 7}, {Val: "3", Col: 10}}},
+		{"é ü", []Token{{Val: "é", Col: 1}, {Val: "ü", Col: 3}}},
+		// Quotes
+		{`echo 'a  b' "c  d"`, []Token{{Val: "echo", Col: 1}, {Val: "a  b", Col: 6}, {Val: "c  d", Col: 13}}},
+		{`a'b'"c"d`, []Token{{Val: "abcd", Col: 1}}},
+		{`'' ""`, []Token{{Val: "", Col: 1}, {Val: "", Col: 4}}},
+		{`'a "b" \c $x'`, []Token{{Val: `a "b" \c $x`, Col: 1}}},
+		{`"a 'b' | ; >"`, []Token{{Val: "a 'b' | ; >", Col: 1}}},
+		// Escapes
+		{`a\ b`, []Token{{Val: "a b", Col: 1}}},
+		{`\|\;\>\'\"\\`, []Token{{Val: `|;>'"\`, Col: 1}}},
+		{`"\" \\ \n \a"`, []Token{{Val: `" \ \n \a`, Col: 1}}},
+		// A $ is kept, Run expands the variables
+		{`"\$x" $x ${y} $?`, []Token{{Val: "$x", Col: 1}, {Val: "$x", Col: 7}, {Val: "${y}", Col: 10}, {Val: "$?", Col: 15}}},
+		// Operators split words and are in the column they are typed
+		{"a|b", []Token{{Val: "a", Col: 1}, {Val: "|", Col: 2, Op: true}, {Val: "b", Col: 3}}},
+		{"é | ü", []Token{{Val: "é", Col: 1}, {Val: "|", Col: 3, Op: true}, {Val: "ü", Col: 5}}},
+		{"a > f >>g", []Token{{Val: "a", Col: 1}, {Val: ">", Col: 3, Op: true}, {Val: "f", Col: 5},
+			{Val: ">>", Col: 7, Op: true}, {Val: "g", Col: 9}}},
+		{">>>", []Token{{Val: ">>", Col: 1, Op: true}, {Val: ">", Col: 3, Op: true}}},
+		{"a > f;b >>f", []Token{{Val: "a", Col: 1}, {Val: ">", Col: 3, Op: true}, {Val: "f", Col: 5},
+			{Val: ";", Col: 6, Op: true}, {Val: "b", Col: 7}, {Val: ">>", Col: 9, Op: true}, {Val: "f", Col: 11}}},
+		{";;", []Token{{Val: ";", Col: 1, Op: true}, {Val: ";", Col: 2, Op: true}}},
+	}
+	for _, tt := range tests {
+		got, err := Lex(tt.s)
+		if err != nil {
+			t.Errorf("Lex(%q): %s", tt.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Lex(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	tests := []struct {
+		s   string
+		err SyntaxError
+	}{
+		{`echo 'a`, SyntaxError{Col: 6, Msg: "unterminated ' quote"}},
+		{`echo a "b \"`, SyntaxError{Col: 8, Msg: `unterminated " quote`}},
+		{`echo a\`, SyntaxError{Col: 7, Msg: "backslash at end of line"}},
+	}
+	for _, tt := range tests {
+		_, err := Lex(tt.s)
+		serr, ok := err.(*SyntaxError)
+		if !ok || *serr != tt.err {
+			t.Errorf("Lex(%q) error %v, want %v", tt.s, err, &tt.err)
+		}
+	}
+}
+
+// Expanding $variables as Run does
+func TestLexVars(t *testing.T) {
+	vars := map[string]string{"x": "1", "y": "a b", "?": "0", "LINE": "5"}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+	tests := []struct {
+		s    string
+		want []string
+		err  *SyntaxError
+	}{
+		{s: "echo $x ${x}2 $x$x", want: []string{"echo", "1", "12", "11"}},
+		{s: "echo $y", want: []string{"echo", "a b"}}, // Not split into words
+		{s: `echo "$y" '$y' \$y "\$y"`, want: []string{"echo", "a b", "$y", "$y", "$y"}},
+		{s: "echo $? ${LINE} $", want: []string{"echo", "0", "5", "$"}},
+		{s: "echo $-x $1", want: []string{"echo", "$-x", "$1"}},
+		{s: "echo $x.y", want: []string{"echo", "1.y"}},
+		{s: "echo $z", err: &SyntaxError{Col: 6, Msg: "undefined variable $z"}},
+		{s: `echo "${z}"`, err: &SyntaxError{Col: 7, Msg: "undefined variable $z"}},
+		{s: "echo ${x", err: &SyntaxError{Col: 6, Msg: "missing } after ${"}},
+		{s: "echo ${}", err: &SyntaxError{Col: 6, Msg: `invalid variable name ""`}},
+		{s: "echo ${1x}", err: &SyntaxError{Col: 6, Msg: `invalid variable name "1x"`}},
+		{s: "echo ${a b}", err: &SyntaxError{Col: 6, Msg: `invalid variable name "a b"`}},
+	}
+	for _, tt := range tests {
+		tokens, err := lex(tt.s, lookup)
+		if tt.err != nil {
+			serr, ok := err.(*SyntaxError)
+			if !ok || *serr != *tt.err {
+				t.Errorf("lex(%q) error %v, want %v", tt.s, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("lex(%q): %s", tt.s, err)
+			continue
+		}
+		if got := Words(tokens); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lex(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", []string{""}},
+		{"  ", []string{"  "}},
+		{"ls", []string{"ls"}},
+		{"ls; help | head 2 ;jobs", []string{"ls", " help | head 2 ", "jobs"}},
+		{"ls;", []string{"ls", ""}},
+		{";;", []string{"", "", ""}},
+		{`echo 'a;b' "c;d" e\;f; ls`, []string{`echo 'a;b' "c;d" e\;f`, " ls"}},
+		{"echo é; ü", []string{"echo é", " ü"}},
+	}
+	for _, tt := range tests {
+		got, err := Split(tt.s)
+		if err != nil {
+			t.Errorf("Split(%q): %s", tt.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+	if _, err := Split("ls; echo 'a"); err == nil {
+		t.Error("Split with an unterminated quote is not an error")
+	}
+}
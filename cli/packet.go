@@ -0,0 +1,73 @@
+// Commands for the packet trace view
+
+package cli
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/charlesetsmith/testgocui/screen"
+)
+
+func init() {
+	Register(Cmd{Name: "packet", Help: "Packet trace view", Category: "Views",
+		Long:     "CtrlP shows or hides the packet view.",
+		Examples: []string{"packet trace hello", "packet trace -rx hello back", "packet save packets.txt", "packet clear"},
+		Subcommands: []Cmd{
+			{Name: "show", Help: "Show what is in the packet view", Fn: packetshow, Positional: &Positional{}},
+			{Name: "clear", Help: "Empty the packet view", Fn: packetclear, Positional: &Positional{}},
+			{Name: "save", Help: "Write what is in the packet view to file",
+				Fn: packetsave, Complete: CompleteFiles, Positional: &Positional{Name: "file", Min: 1, Max: 1}},
+			{Name: "trace", Help: "Add a line to the packet view, as sent or with -rx received", Fn: packettrace,
+				Flags:      []Flag{{Name: "rx", Type: Bool, Help: "A packet received rather than sent"}},
+				Positional: &Positional{Name: "text", Min: 1, Max: -1}},
+		}})
+}
+
+// What is in the packet view
+func packetbuffer(e *Env) (string, error) {
+	b, ok := e.Sink.(Bufferer)
+	if !ok {
+		return "", errors.New("there is no packet view")
+	}
+	return b.Buffer("packet")
+}
+
+// packet show
+func packetshow(e *Env) error {
+	s, err := packetbuffer(e)
+	if err != nil {
+		return err
+	}
+	screen.Fprintf(e.Out, "", "%s", s)
+	return nil
+}
+
+// packet clear
+func packetclear(e *Env) error {
+	c, ok := e.Sink.(Clearer)
+	if !ok {
+		return errors.New("there is no packet view")
+	}
+	return c.Clear("packet")
+}
+
+// packet save <file>
+func packetsave(e *Env) error {
+	s, err := packetbuffer(e)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(e.Args[1], []byte(screen.Uncolour(s)), 0644)
+}
+
+// packet trace [-rx] <text>...
+func packettrace(e *Env) error {
+	style := "packet-tx"
+	if e.Bool("rx") {
+		style = "packet-rx"
+	}
+	screen.Fprintln(e.PacketWriter(), style, strings.Join(e.Args[1:], " "))
+	return nil
+}
@@ -0,0 +1,341 @@
+// Pipelines of a command followed by filters e.g. ls | grep cmd | head 5 > file
+
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charlesetsmith/testgocui/screen"
+)
+
+// InvalidError - The command is not registered, or is not one of Parent's subcommands
+type InvalidError struct {
+	Name      string   // "" when Parent needs a subcommand and none was given
+	Col       int      // Column it was entered at, starting at 1
+	Parent    *Cmd     // Command it should have been a subcommand of, nil for a command
+	Ambiguous []string // With Abbrev the commands Name is a prefix of when there is more than one
+	Suggest   []string // Commands close to Name, did you mean one of them
+}
+
+func (e *InvalidError) Error() string {
+	switch {
+	case len(e.Ambiguous) > 0 && e.Parent == nil:
+		return fmt.Sprintf("ambiguous command %s, one of %s", e.Name, strings.Join(e.Ambiguous, ", "))
+	case len(e.Ambiguous) > 0:
+		return fmt.Sprintf("ambiguous %s subcommand %s, one of %s", e.Parent.path, e.Name, strings.Join(e.Ambiguous, ", "))
+	case e.Parent == nil:
+		return "invalid command " + e.Name + e.hint()
+	case e.Name == "":
+		return fmt.Sprintf("%s needs a subcommand, one of %s", e.Parent.path, strings.Join(e.Parent.SubNames(), ", "))
+	case len(e.Suggest) > 0:
+		return fmt.Sprintf("invalid %s subcommand %s%s", e.Parent.path, e.Name, e.hint())
+	default:
+		return fmt.Sprintf("invalid %s subcommand %s, one of %s", e.Parent.path, e.Name, strings.Join(e.Parent.SubNames(), ", "))
+	}
+}
+
+// stage - One command of a pipeline and its arguments
+type stage struct {
+	cmd   *Cmd
+	args  []string
+	flags map[string]interface{}
+}
+
+// cmdline - A parsed command line, its pipeline and where the output goes
+type cmdline struct {
+	stages []stage
+	file   string // Output is redirected to file, "" for the msg view
+	append bool   // >> append to file rather than > overwrite it
+}
+
+// Parse the tokens into a pipeline with an optional > or >> file on the end
+func parse(tokens []Token) (*cmdline, error) {
+	var err error
+
+	cl := &cmdline{}
+	for i, t := range tokens {
+		if t.Op && t.Val == ";" { // Split() should have taken these out
+			return nil, &SyntaxError{Col: t.Col, Msg: "unexpected ;"}
+		}
+		if !t.Op || (t.Val != ">" && t.Val != ">>") {
+			continue
+		}
+		switch {
+		case i+1 == len(tokens) || tokens[i+1].Op:
+			return nil, &SyntaxError{Col: t.Col, Msg: "missing file name after " + t.Val}
+		case i+2 < len(tokens):
+			return nil, &SyntaxError{Col: tokens[i+2].Col, Msg: "unexpected " + tokens[i+2].Val + " after file name"}
+		}
+		cl.file = tokens[i+1].Val
+		cl.append = t.Val == ">>"
+		tokens = tokens[:i]
+		break
+	}
+	if cl.stages, err = pipeline(tokens); err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+// Run the command line with output to out or the redirected file
+func (cl *cmdline) run(ctx context.Context, sink Sink, out io.Writer, cmds Cmdhist) error {
+	if cl.file == "" {
+		return runpipeline(ctx, sink, cl.stages, out, cmds)
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if cl.append {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(cl.file, flags, 0644)
+	if err != nil {
+		return err
+	}
+	w := &errWriter{w: screen.Plain(f)}
+	err = runpipeline(ctx, sink, cl.stages, w, cmds)
+	if cerr := f.Close(); w.err == nil {
+		w.err = cerr
+	}
+	if err == nil && w.err != nil {
+		err = w.err
+	}
+	return err
+}
+
+// errWriter - Remembers the first error writing to w as commands don't check what they write
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(b []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	var n int
+	n, e.err = e.w.Write(b)
+	return n, e.err
+}
+
+// Split the tokens at each | into the stages of a pipeline,
+// the first stage must be a command and the rest filters
+func pipeline(tokens []Token) ([]stage, error) {
+	var stages []stage
+	var words []Token
+
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && !tokens[i].Op {
+			words = append(words, tokens[i])
+			continue
+		}
+		if len(words) == 0 {
+			if i < len(tokens) {
+				return nil, &SyntaxError{Col: tokens[i].Col, Msg: "missing command before " + tokens[i].Val}
+			}
+			return nil, &SyntaxError{Col: tokens[i-1].Col, Msg: "missing filter after " + tokens[i-1].Val}
+		}
+		c, n := Find(Words(words))
+		switch {
+		case c == nil:
+			return nil, invalid(words[0].Val, words[0].Col, nil)
+		case c.Fn == nil && c.Filter == nil && n < len(words): // Only its subcommands run
+			return nil, invalid(words[n].Val, words[n].Col, c)
+		case c.Fn == nil && c.Filter == nil:
+			return nil, &InvalidError{Col: words[n-1].Col, Parent: c}
+		case len(stages) == 0 && c.Fn == nil:
+			return nil, &SyntaxError{Col: words[0].Col, Msg: c.path + " can only be used after |"}
+		case len(stages) > 0 && c.Filter == nil:
+			return nil, &SyntaxError{Col: words[0].Col, Msg: c.path + " is not a filter"}
+		}
+		flags, args, err := c.parseargs(Words(words[n:]))
+		if err != nil {
+			return nil, err
+		}
+		// The command's full name then its arguments
+		stages = append(stages, stage{cmd: c, args: append([]string{c.path}, args...), flags: flags})
+		words = nil
+	}
+	return stages, nil
+}
+
+// Run the command with its output going through each filter to out
+func runpipeline(ctx context.Context, sink Sink, stages []stage, out io.Writer, cmds Cmdhist) error {
+	var wg sync.WaitGroup
+
+	errs := make([]error, len(stages))
+	// Stage i writes to pipes[i] which stage i+1 reads, the last stage writes to out
+	readers := make([]*io.PipeReader, len(stages))
+	pipes := make([]*io.PipeWriter, len(stages)-1)
+	for i := range pipes {
+		readers[i+1], pipes[i] = io.Pipe()
+	}
+	env := func(i int) *Env {
+		e := &Env{Context: ctx, Sink: sink, Args: stages[i].args, Hist: cmds, Out: out, Job: JobFrom(ctx),
+			flags: stages[i].flags}
+		if i > 0 {
+			e.In = readers[i]
+		}
+		if i < len(pipes) {
+			e.Out = pipes[i]
+		}
+		return e
+	}
+	for i := 1; i < len(stages); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = stages[i].cmd.Filter(env(i))
+			// Stop anything still writing to us and tell the next filter we are done
+			readers[i].Close()
+			if i < len(pipes) {
+				pipes[i].CloseWithError(errs[i])
+			}
+		}(i)
+	}
+	errs[0] = stages[0].cmd.Fn(env(0))
+	if len(pipes) > 0 {
+		pipes[0].CloseWithError(errs[0])
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("%s: %w", stages[i].args[0], err)
+		}
+	}
+	return nil
+}
+
+/* ************************************************************************** */
+
+// The built in filters
+func init() {
+	Register(Cmd{Name: "grep", Help: "Only lines matching the regular expression, -v those not matching, -i ignore case",
+		Filter: grep, Category: "Filters",
+		Long:     "The pattern is matched against the text without its colours.",
+		Examples: []string{"ls | grep help", "jobs | grep -v -i sleep"},
+		Flags: []Flag{
+			{Name: "v", Type: Bool, Help: "Lines not matching"},
+			{Name: "i", Type: Bool, Help: "Ignore case"},
+		},
+		Positional: &Positional{Name: "pattern", Min: 1, Max: 1}})
+	Register(Cmd{Name: "head", Help: "First n (default 10) lines", Filter: head, Category: "Filters",
+		Positional: &Positional{Name: "n", Max: 1}})
+	Register(Cmd{Name: "tail", Help: "Last n (default 10) lines", Filter: tail, Category: "Filters",
+		Positional: &Positional{Name: "n", Max: 1}})
+	Register(Cmd{Name: "sort", Help: "Sort lines, -r reversed", Filter: sortlines, Category: "Filters",
+		Flags: []Flag{{Name: "r", Type: Bool, Help: "Reversed"}}, Positional: &Positional{}})
+	Register(Cmd{Name: "wc", Help: "Count lines, words and characters", Filter: wc, Category: "Filters",
+		Positional: &Positional{}})
+}
+
+// Read all of the lines from in
+func readlines(in io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// Line count argument for head & tail
+func linecount(args []string) (int, error) {
+	if len(args) == 1 {
+		return 10, nil
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number of lines %s", args[1])
+	}
+	return n, nil
+}
+
+// grep [-v] [-i] <pattern>
+func grep(e *Env) error {
+	var flags string
+
+	if e.Bool("i") {
+		flags = "(?i)"
+	}
+	re, err := regexp.Compile(flags + e.Args[1])
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(e.In)
+	for scanner.Scan() {
+		// Match the text not the colour escape sequences
+		if re.MatchString(screen.Uncolour(scanner.Text())) != e.Bool("v") {
+			fmt.Fprintln(e.Out, scanner.Text())
+		}
+	}
+	return scanner.Err()
+}
+
+// head [n]
+func head(e *Env) error {
+	n, err := linecount(e.Args)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(e.In)
+	for i := 0; i < n && scanner.Scan(); i++ {
+		fmt.Fprintln(e.Out, scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// tail [n]
+func tail(e *Env) error {
+	n, err := linecount(e.Args)
+	if err != nil {
+		return err
+	}
+	lines, err := readlines(e.In)
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	for _, l := range lines {
+		fmt.Fprintln(e.Out, l)
+	}
+	return err
+}
+
+// sort [-r]
+func sortlines(e *Env) error {
+	reverse := e.Bool("r")
+	lines, err := readlines(e.In)
+	sort.SliceStable(lines, func(i, j int) bool {
+		if reverse {
+			return screen.Uncolour(lines[i]) > screen.Uncolour(lines[j])
+		}
+		return screen.Uncolour(lines[i]) < screen.Uncolour(lines[j])
+	})
+	for _, l := range lines {
+		fmt.Fprintln(e.Out, l)
+	}
+	return err
+}
+
+// wc - lines words characters
+func wc(e *Env) error {
+	var nlines, nwords, nchars int
+
+	lines, err := readlines(e.In)
+	for _, l := range lines {
+		l = screen.Uncolour(l)
+		nlines++
+		nwords += len(strings.Fields(l))
+		nchars += len([]rune(l)) + 1
+	}
+	fmt.Fprintf(e.Out, "%d %d %d\n", nlines, nwords, nchars)
+	return err
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPipelines(t *testing.T) {
+	testDocmd(t, []docmdTest{
+		{cmd: "ca -n 3 -prefix x one | head 2", msg: "x[ca one]\nx[ca one]\n"},
+		{cmd: "echo b | grep a", msg: ""},
+		{cmd: "echo b | grep -v a", msg: "b\n"},
+		{cmd: "ca -n 3 -prefix x one | tail 1 | wc", msg: "1 2 10\n"},
+	})
+}
+
+// The file has no colours
+func TestRedirects(t *testing.T) {
+	dir := t.TempDir()
+	out, over := filepath.Join(dir, "out"), filepath.Join(dir, "over")
+	testDocmd(t, []docmdTest{
+		{cmd: "ca -prefix x one > " + Quote(out) + "; echo two >> " + Quote(out), msg: ""},
+		{cmd: "echo a > " + Quote(over) + "; echo b | head 1 > " + Quote(over), msg: ""},
+		{cmd: "echo a > /nosuchdir/out", err: "/nosuchdir/out", failed: true},
+	})
+	for file, want := range map[string]string{out: "x[ca one]\ntwo\n", over: "b\n"} {
+		if b, err := os.ReadFile(file); err != nil || string(b) != want {
+			t.Errorf("%s has %q %v, want %q", file, b, err, want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Running scripts of commands
+
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Rcfile - Script sourced at startup, defaults to $XDG_CONFIG_HOME/testgocui/rc (~/.config/testgocui/rc)
+var Rcfile = defaultrcfile()
+
+func defaultrcfile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "testgocui", "rc")
+}
+
+func init() {
+	Register(Cmd{Name: "source", Aliases: []string{"."},
+		Help: "Run the commands in file one after the other, -e stops at the first error",
+		Fn:   source, Complete: CompleteFiles, Category: "Scripts",
+		Long: "Blank lines and lines starting with # are skipped, errors are shown with the file and line number. " +
+			"Scripts can source scripts up to 16 deep. The rc file is run this way at startup.",
+		Examples:   []string{"source setup", ". -e setup"},
+		Flags:      []Flag{{Name: "e", Type: Bool, Help: "Stop at the first error"}},
+		Positional: &Positional{Name: "file", Min: 1, Max: 1}})
+}
+
+// How deep scripts can source scripts, e.g. to stop one that sources itself
+const maxsource = 16
+
+// Key for how many Source calls a command is running in
+type sourceKey struct{}
+
+// nestedError - Stops every script it is nested in, not just the one that went too deep
+type nestedError struct {
+	file string
+}
+
+func (e *nestedError) Error() string {
+	return e.file + ": source nested too deep"
+}
+
+// Source - Run each line of the file in turn with e's output and history, waiting for each to finish.
+// Blank lines and lines starting with # are skipped, errors are reported on e's err writer
+// with the file and line number. If stoponerr is set the first error stops the script, exit always does.
+func Source(e *Env, file string, stoponerr bool) error {
+	var failed int
+	var xerr *ExitError
+	var nerr *nestedError
+
+	depth, _ := e.Value(sourceKey{}).(int)
+	if depth >= maxsource {
+		return &nestedError{file: file}
+	}
+	ctx := context.WithValue(e, sourceKey{}, depth+1)
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		where := fmt.Sprintf("%s:%d: ", file, n)
+		cmdlines, err := Split(line)
+		if err != nil { // Run will find the same problem and we report it
+			cmdlines = []string{line}
+		}
+		for _, cmdline := range cmdlines {
+			err := Run(ctx, e.Sink, e.Out, cmdline, e.Hist)
+			if errors.Is(err, context.Canceled) || e.Err() != nil {
+				return e.Err()
+			}
+			if errors.As(err, &xerr) {
+				return err
+			}
+			if errors.As(err, &nerr) { // Without each source it went through
+				return nerr
+			}
+			if err != nil {
+				Report(e.Sink, where, cmdline, err)
+				if stoponerr {
+					return fmt.Errorf("%sstopped at error", where)
+				}
+				failed++
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%s: %d commands failed", file, failed)
+	}
+	return nil
+}
+
+// source [-e] <file>
+func source(e *Env) error {
+	return Source(e, e.Args[1], e.Bool("e"))
+}
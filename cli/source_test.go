@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Write the script into dir, returning its path
+func script(t *testing.T, dir string, name string, lines ...string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestSource(t *testing.T) {
+	dir := t.TempDir()
+	a := script(t, dir, "a", "# A comment", "", "echo one", "  nosuch", "echo two; hlep; echo three")
+	loop := filepath.Join(dir, "loop")
+	script(t, dir, "loop", "echo in", "source "+Quote(loop))
+	exit := script(t, dir, "exit", "echo a", "exit 3", "echo b")
+	outer := script(t, dir, "outer", ". "+Quote(exit), "echo after")
+	testDocmd(t, []docmdTest{
+		// Errors have the file and line number and the script carries on
+		{cmd: "source " + Quote(a), msg: "one\ntwo\nthree\n", err: a + ":4: Invalid command: nosuch\n" +
+			a + ":5: Invalid command: hlep, did you mean help?\nsource: " + a + ": 2 commands failed\n", failed: true},
+		{cmd: "source -e " + Quote(a), msg: "one\n", err: a + ":4: Invalid command: nosuch\nsource: " + a + ":4: stopped at error\n",
+			failed: true},
+		{cmd: "source " + Quote(filepath.Join(dir, "nosuch")), err: filepath.Join(dir, "nosuch"), failed: true},
+		// Stopped however deep it got
+		{cmd: "source " + Quote(loop), msg: strings.Repeat("in\n", maxsource), err: loop + ": source nested too deep\n",
+			failed: true},
+		// exit stops every script and the line it is on
+		{cmd: "source " + Quote(outer) + "; echo no", msg: "a\n", failed: true},
+	})
+	// Reported the once, not by every script it went through
+	b := &Buffer{}
+	Docmd(context.Background(), b, "source "+Quote(loop), Cmdhist{})
+	if errs, _ := b.Buffer("err"); strings.Count(errs, "nested too deep") != 1 {
+		t.Errorf("source %s: err %q", loop, errs)
+	}
+}
+
+func TestDefaultRcfile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	if config, err := os.UserConfigDir(); err != nil || config != dir {
+		t.Skip("XDG_CONFIG_HOME is not the config directory here")
+	}
+	if got, want := defaultrcfile(), filepath.Join(dir, "testgocui", "rc"); got != want {
+		t.Errorf("Rcfile %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,142 @@
+// Did you mean suggestions for mistyped commands and shortening commands to a unique prefix
+
+package cli
+
+import (
+	"sort"
+	"strings"
+)
+
+// Abbrev - Let commands and subcommands be shortened to any prefix only one of them has e.g. hel for help
+var Abbrev bool
+
+// Most suggestions to make
+const maxsuggest = 3
+
+// The command called name in names, or with Abbrev the only command name is a prefix of.
+// If name is a prefix of more than one command they are returned instead, sorted.
+func resolve(names map[string]*Cmd, name string) (*Cmd, []string) {
+	var c *Cmd
+	var matches []string
+
+	if c, ok := names[name]; ok {
+		return c, nil
+	}
+	if !Abbrev || name == "" {
+		return nil, nil
+	}
+	ambiguous := false
+	for n, m := range names {
+		if !strings.HasPrefix(n, name) {
+			continue
+		}
+		matches = append(matches, n)
+		if c != nil && c != m { // A command and its alias is still just the one
+			ambiguous = true
+		}
+		c = m
+	}
+	if ambiguous {
+		sort.Strings(matches)
+		return nil, matches
+	}
+	return c, nil
+}
+
+// Edit distance between a and b, the fewest characters inserted, deleted, changed or
+// swapped with the next to turn one into the other
+func distance(a string, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+// Suggest - The names closest to name, those the fewest edits away or starting with it
+func Suggest(name string, names []string) []string {
+	type match struct {
+		name string
+		d    int
+	}
+	var matches []match
+
+	if name == "" {
+		return nil
+	}
+	limit := 1 // Edits allowed, more for longer names
+	if len([]rune(name)) > 3 {
+		limit = 2
+	}
+	for _, n := range names {
+		d := distance(name, n)
+		switch {
+		case strings.HasPrefix(n, name):
+			d = min(d, 1)
+		case d >= len([]rune(name)): // Nothing of name is left
+			continue
+		}
+		if d <= limit && n != name {
+			matches = append(matches, match{n, d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].d != matches[j].d {
+			return matches[i].d < matches[j].d
+		}
+		return matches[i].name < matches[j].name
+	})
+	var suggest []string
+	for i := 0; i < len(matches) && i < maxsuggest && matches[i].d == matches[0].d; i++ {
+		suggest = append(suggest, matches[i].name)
+	}
+	return suggest
+}
+
+// The error for name not being a command, or a subcommand of parent if it is set,
+// with the commands it could be short for or might have been meant
+func invalid(name string, col int, parent *Cmd) *InvalidError {
+	var names []string
+
+	e := &InvalidError{Name: name, Col: col, Parent: parent}
+	if parent == nil {
+		regMu.RLock()
+		_, e.Ambiguous = resolve(lookup, name)
+		regMu.RUnlock()
+		names = append(append(Names(false), Names(true)...), AliasNames()...)
+	} else {
+		_, e.Ambiguous = resolve(parent.subs, name)
+		for n := range parent.subs {
+			names = append(names, n)
+		}
+	}
+	if e.Ambiguous == nil {
+		e.Suggest = Suggest(name, names)
+	}
+	return e
+}
+
+// ", did you mean x or y?" for the suggestions, "" if there are none
+func (e *InvalidError) hint() string {
+	if len(e.Suggest) == 0 {
+		return ""
+	}
+	return ", did you mean " + strings.Join(e.Suggest, " or ") + "?"
+}
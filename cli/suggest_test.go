@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"help", "help", 0},
+		{"hlep", "help", 1}, // Swapped
+		{"hep", "help", 1},
+		{"kitten", "sitting", 3},
+		{"é", "e", 1},
+	}
+	for _, tt := range tests {
+		if got := distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("distance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	names := []string{"help", "head", "heap", "heal", "heat", "kill", "killall", "ls", "sort"}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"hlep", []string{"help"}},
+		{"hea", []string{"head", "heal", "heap"}}, // At most 3
+		{"kil", []string{"kill", "killall"}},
+		{"kill", []string{"killall"}},
+		{"sl", []string{"ls"}},
+		{"xyz", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := Suggest(tt.name, names); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Suggest(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	defer func() { Abbrev = false }()
+	testDocmd(t, []docmdTest{
+		{cmd: "hlep", err: "Invalid command: hlep, did you mean help?\n", failed: true},
+		{cmd: "echo a | hed 1", err: "Invalid command: hed, did you mean head?\n", failed: true},
+		{cmd: "xyzzy", err: "Invalid command: xyzzy\n", failed: true},
+		{cmd: "packet sohw", err: "invalid packet subcommand sohw, did you mean show?", failed: true},
+	})
+	Abbrev = true
+	testDocmd(t, []docmdTest{
+		{cmd: "ech a", msg: "a\n"},
+		{cmd: "h", err: "ambiguous command h, one of head, help", failed: true},
+	})
+}
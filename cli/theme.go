@@ -0,0 +1,57 @@
+// Switching the colour theme, what colour each style (error, prompt...) is
+
+package cli
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/charlesetsmith/testgocui/screen"
+)
+
+func init() {
+	Register(Cmd{Name: "theme", Help: "Switch to the colour theme, or list the themes and the styles of this one",
+		Long: "Bundled are dark, light and high-contrast. Your own are files of style = colour lines, " +
+			"name.theme in the themes directory, styles they leave out are as in dark. " +
+			"What is already on the screen keeps its colours.",
+		Examples: []string{"theme", "theme light", "theme ./mine.theme"},
+		Fn:       theme, Complete: CompleteThemes, Category: "Views", Positional: &Positional{Name: "name", Max: 1}})
+}
+
+// CompleteThemes - Completer for arguments that are theme names
+func CompleteThemes(args []string, word string) []string {
+	return prefixed(screen.ThemeNames(), word)
+}
+
+// Sorted names of the styles
+func styles() []string {
+	names := make([]string, 0, len(screen.Styles))
+	for s := range screen.Styles {
+		names = append(names, s)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// theme [name]
+func theme(e *Env) error {
+	if len(e.Args) == 2 {
+		if err := screen.SetTheme(e.Args[1]); err != nil {
+			return err
+		}
+		screen.Fprintf(e.Out, "info", "Theme %s\n", e.Args[1])
+		return nil
+	}
+	names := screen.ThemeNames()
+	for i, n := range names {
+		if n == screen.ThemeName() {
+			names[i] += "*"
+		}
+	}
+	helpsection(e.Out, "Themes", "  "+strings.Join(names, " ")+"\n")
+	screen.Fprintf(e.Out, "title", "Styles of %s:\n", screen.ThemeName())
+	for _, s := range styles() { // Each in its own colour
+		screen.Fprintf(e.Out, s, "  %-10s %s\n", s, screen.Styles[s])
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+// Session variables expanded as $name or ${name} in command lines
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/charlesetsmith/testgocui/screen"
+)
+
+// Session variables set with "set" and their lock
+var varsMu sync.RWMutex
+var vars = map[string]string{}
+
+// Exit status of the last command line to finish, $?
+var status int
+
+// Builtins - Read only variables and what they are
+var Builtins = map[string]string{
+	"?":    "Status of the last command, 0 ok, 1 failed, 130 interrupted",
+	"LINE": "Line number of the current prompt",
+}
+
+func init() {
+	Register(Cmd{Name: "set", Usage: "set <name> <value>...", Help: "Set the variable $name to the values joined by spaces",
+		Examples: []string{"set name World; echo Hello $name"},
+		Fn:       setcmd, Complete: CompleteVars, Category: "Variables", Positional: &Positional{Name: "value", Min: 2, Max: -1}})
+	Register(Cmd{Name: "unset", Help: "Remove the variables", Fn: unset,
+		Complete: CompleteVars, Category: "Variables", Positional: &Positional{Name: "name", Min: 1, Max: -1}})
+	Register(Cmd{Name: "vars", Help: "List the variables", Fn: varscmd, Category: "Variables", Positional: &Positional{}})
+	Register(Cmd{Name: "env", Help: "List the process environment, or just the names given",
+		Fn: env, Category: "Variables", Positional: &Positional{Name: "name", Max: -1}})
+	Register(Cmd{Name: "echo", Help: "Show the arguments, e.g. echo $name", Fn: echo,
+		Category: "General", Positional: &Positional{Name: "arg", Max: -1}})
+}
+
+// Is s a valid variable name, a letter or _ then letters, digits or _
+func varname(s string) bool {
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// SetVar - Set the session variable name to value
+func SetVar(name string, value string) error {
+	if _, ok := Builtins[name]; ok {
+		return fmt.Errorf("%s is read only", name)
+	}
+	if !varname(name) {
+		return fmt.Errorf("invalid variable name %q", name)
+	}
+	varsMu.Lock()
+	defer varsMu.Unlock()
+	vars[name] = value
+	return nil
+}
+
+// UnsetVar - Remove the session variable name
+func UnsetVar(name string) error {
+	if _, ok := Builtins[name]; ok {
+		return fmt.Errorf("%s is read only", name)
+	}
+	varsMu.Lock()
+	defer varsMu.Unlock()
+	if _, ok := vars[name]; !ok {
+		return fmt.Errorf("no variable %s", name)
+	}
+	delete(vars, name)
+	return nil
+}
+
+// LookupVar - Value of $name, the built ins then the session variables then the process environment
+func LookupVar(name string, cmds Cmdhist) (string, bool) {
+	switch name {
+	case "?":
+		varsMu.RLock()
+		defer varsMu.RUnlock()
+		return strconv.Itoa(status), true
+	case "LINE":
+		return strconv.Itoa(cmds.Curline), true
+	}
+	varsMu.RLock()
+	value, ok := vars[name]
+	varsMu.RUnlock()
+	if ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+// VarNames - Sorted names of the session variables
+func VarNames() []string {
+	varsMu.RLock()
+	defer varsMu.RUnlock()
+	names := make([]string, 0, len(vars))
+	for n := range vars {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CompleteVars - Completer for arguments that are session variable names
+func CompleteVars(args []string, word string) []string {
+	if len(args) > 1 && args[0] == "set" { // Only the name is completed
+		return nil
+	}
+	return prefixed(VarNames(), word)
+}
+
+// Remember how the command line that just finished went for $?
+func setstatus(err error) {
+	varsMu.Lock()
+	defer varsMu.Unlock()
+	switch {
+	case err == nil:
+		status = 0
+	case errors.Is(err, context.Canceled):
+		status = 130
+	default:
+		status = 1
+	}
+}
+
+// set <name> <value>...
+func setcmd(e *Env) error {
+	return SetVar(e.Args[1], strings.Join(e.Args[2:], " "))
+}
+
+// unset <name>...
+func unset(e *Env) error {
+	for _, name := range e.Args[1:] {
+		if err := UnsetVar(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vars - The session variables then the built ins
+func varscmd(e *Env) error {
+	var s string
+
+	for _, name := range VarNames() {
+		value, _ := LookupVar(name, e.Hist)
+		s += fmt.Sprintf("%s=%s\n", name, value)
+	}
+	builtins := make([]string, 0, len(Builtins))
+	for name := range Builtins {
+		builtins = append(builtins, name)
+	}
+	sort.Strings(builtins)
+	for _, name := range builtins {
+		value, _ := LookupVar(name, e.Hist)
+		s += fmt.Sprintf("%s=%s (read only) %s\n", name, value, Builtins[name])
+	}
+	screen.Fprintf(e.Out, "output", "%s", s)
+	return nil
+}
+
+// env [name]...
+func env(e *Env) error {
+	var s string
+
+	if len(e.Args) == 1 {
+		environ := os.Environ()
+		sort.Strings(environ)
+		s = strings.Join(environ, "\n") + "\n"
+	}
+	for _, name := range e.Args[1:] {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return fmt.Errorf("%s is not set", name)
+		}
+		s += fmt.Sprintf("%s=%s\n", name, value)
+	}
+	screen.Fprintf(e.Out, "output", "%s", s)
+	return nil
+}
+
+// echo [arg]...
+func echo(e *Env) error {
+	screen.Fprintln(e.Out, "", strings.Join(e.Args[1:], " "))
+	return nil
+}
@@ -0,0 +1,16 @@
+package cli
+
+import "testing"
+
+func TestVariables(t *testing.T) {
+	defer UnsetVar("x")
+	defer UnsetVar("y")
+	testDocmd(t, []docmdTest{
+		{cmd: "set x 42; echo $x ${x}y '$x' \\$x \"$x\"", msg: "42 42y $x $x 42\n"},
+		{cmd: "set y 'a  b'; echo \"$y\" $y", msg: "a  b a  b\n"}, // A value is one word
+		{cmd: "echo $?", msg: "0\n"},
+		{cmd: "set 1x 2", err: `invalid variable name "1x"`, failed: true},
+		{cmd: "echo $nosuchvariable", err: "nosuchvariable", failed: true},
+		{cmd: "unset x; echo ${x}", err: "x", failed: true},
+	})
+}
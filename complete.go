@@ -0,0 +1,53 @@
+// Tab completion in the cmd view
+
+package main
+
+import (
+	"strings"
+
+	"github.com/charlesetsmith/testgocui/cli"
+	"github.com/charlesetsmith/testgocui/screen"
+	"github.com/jroimartin/gocui"
+)
+
+// Longest prefix all of the words share
+func commonprefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			r := []rune(prefix)
+			prefix = string(r[:len(r)-1])
+		}
+	}
+	return prefix
+}
+
+// Tab in the cmd view - Complete the word before the cursor, when there is
+// more than one candidate fill in what they share and list them in the msg view
+func complete(g *gocui.Gui, v *gocui.View) error {
+	if searching {
+		return searchAccept(g, v)
+	}
+	text := []rune(cmdtext(v))
+	cx, _ := v.Cursor()
+	if cx != promptlen(Cinfo)+len(text) { // Only at the end of the line
+		return nil
+	}
+	col, candidates := cli.Complete(string(text))
+	switch len(candidates) {
+	case 0:
+		return nil
+	case 1:
+		s := cli.Quote(candidates[0])
+		if !strings.HasSuffix(s, "/") { // Ready for the next word
+			s += " "
+		}
+		setcmdtext(v, string(text[:col-1])+s)
+	default:
+		if s := cli.Quote(commonprefix(candidates)); len([]rune(s)) > len(text)-(col-1) {
+			setcmdtext(v, string(text[:col-1])+s)
+		}
+		screen.MsgPrintln(g, "output", strings.Join(candidates, "  "))
+	}
+	return nil
+}
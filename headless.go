@@ -0,0 +1,124 @@
+// Running the commands without gocui, e.g. from a script, cron or CI
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/charlesetsmith/testgocui/cli"
+	"github.com/charlesetsmith/testgocui/screen"
+)
+
+// Only keep the colours when they are going to a terminal
+func output(f *os.File) io.Writer {
+	if isterminal(f) {
+		return f
+	}
+	return screen.Plain(f)
+}
+
+// Where packet view output goes for -packet, "" nowhere, "-" stdout,
+// "&n" file descriptor n otherwise it is appended to the file.
+// True when it opened the file so it is ours to close, stdout and &n belong to whoever started us.
+func packetoutput(dest string) (*os.File, bool, error) {
+	switch {
+	case dest == "":
+		return nil, false, nil
+	case dest == "-":
+		return os.Stdout, false, nil
+	case strings.HasPrefix(dest, "&"):
+		fd, err := strconv.Atoi(dest[1:])
+		if err != nil || fd < 0 {
+			return nil, false, fmt.Errorf("invalid packet file descriptor %s", dest)
+		}
+		return os.NewFile(uintptr(fd), "packet"), false, nil
+	default:
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		return f, err == nil, err
+	}
+}
+
+// Run the commands in s (cmd; cmd) one after the other, false if one was exit
+func runheadless(ctx context.Context, sink cli.Sink, s string, status *int) bool {
+	lines, err := cli.Split(s)
+	if err != nil { // Docmd reports it
+		lines = []string{s}
+	}
+	for _, line := range lines {
+		var xerr *cli.ExitError
+
+		err := cli.Docmd(ctx, sink, line, Cinfo)
+		switch {
+		case errors.As(err, &xerr): // exit n, or exit keeping the status so far
+			if xerr.Status >= 0 {
+				*status = xerr.Status
+			}
+			return false
+		case err != nil:
+			*status = 1
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Headless - Run the commands from -c, or each line of stdin, without gocui.
+// msg output goes to stdout, err to stderr and packet to -packet.
+// Returns the exit status, 1 if any command failed.
+func headless(commands string, packet string) int {
+	status := 0
+
+	pw, opened, err := packetoutput(packet)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if opened {
+		defer pw.Close()
+	}
+	sink := &cli.Writers{Msg: output(os.Stdout), Err: output(os.Stderr)}
+	if pw != nil {
+		sink.Packet = output(pw)
+	}
+	screen.SetReport(sink.Err)
+
+	// CtrlC interrupts whatever is running and stops
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if _, err := os.Stat(cli.Rcfile); cli.Rcfile != "" && err == nil {
+		if !runheadless(ctx, sink, "source "+cli.Quote(cli.Rcfile), &status) {
+			return status
+		}
+	}
+	if commands != "" {
+		runheadless(ctx, sink, commands, &status)
+		return status
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !runheadless(ctx, sink, line, &status) {
+			break
+		}
+		Cinfo.Curline++
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return status
+}
@@ -0,0 +1,212 @@
+// Command history recall and search in the cmd view
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charlesetsmith/testgocui/screen"
+	"github.com/jroimartin/gocui"
+)
+
+// Where we are in Cinfo.Commands recalling history with Up/Down,
+// len(Cinfo.Commands) is the line being typed
+var histpos int
+
+// What had been typed on the prompt line before we started recalling history
+var histsaved string
+
+// Start again from the newest history entry, called after a command is entered
+func historyReset() {
+	histpos = len(Cinfo.Commands)
+	histsaved = ""
+}
+
+// What has been typed after the prompt on the current cmd line
+func cmdtext(v *gocui.View) string {
+	_, cy := v.Cursor()
+	line, _ := v.Line(cy)
+	r := []rune(line)
+	if len(r) <= promptlen(Cinfo) {
+		return ""
+	}
+	return string(r[promptlen(Cinfo):])
+}
+
+// Delete back along the current cmd line until the cursor is at column col
+func cmdclear(v *gocui.View, col int) {
+	_, cy := v.Cursor()
+	line, _ := v.Line(cy)
+	v.SetCursor(len([]rune(line)), cy)
+	for cx, _ := v.Cursor(); cx > col; {
+		v.EditDelete(true)
+		ncx, _ := v.Cursor()
+		if ncx == cx { // Can't go back any further
+			break
+		}
+		cx = ncx
+	}
+}
+
+// Replace what has been typed after the prompt on the current cmd line with s
+func setcmdtext(v *gocui.View, s string) {
+	cmdclear(v, promptlen(Cinfo))
+	for _, r := range s {
+		v.EditWrite(r)
+	}
+}
+
+// Replace the whole of the current cmd line with prefix in colour followed by s,
+// the cursor goes on the end. Only for the last line of the view which is where we type.
+func setcmdline(v *gocui.View, colour string, prefix string, s string) {
+	cmdclear(v, 0)
+	fmt.Fprint(v, screen.Colour(colour, prefix), s)
+	_, cy := v.Cursor()
+	v.SetCursor(len([]rune(prefix+s)), cy)
+}
+
+// Up arrow in the cmd view - Replace the line with the previous command
+func historyUp(g *gocui.Gui, v *gocui.View) error {
+	if histpos > len(Cinfo.Commands) {
+		histpos = len(Cinfo.Commands)
+	}
+	if histpos == 0 {
+		return nil
+	}
+	if histpos == len(Cinfo.Commands) { // Keep what was being typed
+		histsaved = cmdtext(v)
+	}
+	histpos--
+	setcmdtext(v, Cinfo.Commands[histpos])
+	return nil
+}
+
+// Down arrow in the cmd view - Replace the line with the next command,
+// past the newest get back what was being typed
+func historyDown(g *gocui.Gui, v *gocui.View) error {
+	if histpos >= len(Cinfo.Commands) {
+		return nil
+	}
+	histpos++
+	if histpos == len(Cinfo.Commands) {
+		setcmdtext(v, histsaved)
+	} else {
+		setcmdtext(v, Cinfo.Commands[histpos])
+	}
+	return nil
+}
+
+// CtrlR reverse incremental history search state
+var searching bool
+var searchquery string // What has been typed to search for
+var searchpos int      // Index in Cinfo.Commands of the current match
+var searchfound bool   // Does Cinfo.Commands[searchpos] match searchquery
+var searchsaved string // What was on the prompt line before the search, Esc puts it back
+
+// Look back through the history from index from for a command containing searchquery
+func searchback(from int) {
+	if from >= len(Cinfo.Commands) {
+		from = len(Cinfo.Commands) - 1
+	}
+	for i := from; i >= 0; i-- {
+		if strings.Contains(Cinfo.Commands[i], searchquery) {
+			searchpos = i
+			searchfound = true
+			return
+		}
+	}
+	searchfound = false
+}
+
+// Show the search in place of the prompt e.g. (reverse-i-search)`ls': ls | wc
+func searchshow(v *gocui.View) {
+	if searchquery == "" { // Nothing to look for yet, it has not failed
+		setcmdline(v, "prompt", "(reverse-i-search)`': ", "")
+		return
+	}
+	if !searchfound {
+		setcmdline(v, "warning", fmt.Sprintf("(failed reverse-i-search)`%s': ", searchquery), "")
+		return
+	}
+	setcmdline(v, "prompt", fmt.Sprintf("(reverse-i-search)`%s': ", searchquery), Cinfo.Commands[searchpos])
+}
+
+// Finish the search putting the prompt back followed by s
+func searchend(v *gocui.View, s string) {
+	searching = false
+	setcmdline(v, "prompt", promptstr(), s)
+	historyReset()
+}
+
+// CtrlR in the cmd view - Start searching back through the history,
+// again finds the next older match
+func searchHistory(g *gocui.Gui, v *gocui.View) error {
+	if !searching {
+		searching = true
+		searchsaved = cmdtext(v)
+		searchquery = ""
+		searchpos = len(Cinfo.Commands)
+		searchfound = false
+	} else if searchquery != "" && searchfound {
+		searchback(searchpos - 1)
+		if !searchfound { // Stay on the oldest one we found
+			searchback(searchpos)
+		}
+	}
+	searchshow(v)
+	return nil
+}
+
+// Add a typed character to what we are searching for
+func searchType(v *gocui.View, ch rune) {
+	searchquery += string(ch)
+	searchback(searchpos)
+	searchshow(v)
+}
+
+// Backspace while searching - Remove the last character searched for and look again from the newest
+func searchBackspace(g *gocui.Gui, v *gocui.View) error {
+	if r := []rune(searchquery); len(r) > 0 {
+		searchquery = string(r[:len(r)-1])
+	}
+	searchpos = len(Cinfo.Commands)
+	searchfound = false
+	if searchquery != "" {
+		searchback(searchpos)
+	}
+	searchshow(v)
+	return nil
+}
+
+// Leave the search with the command found on the prompt line
+func searchAccept(g *gocui.Gui, v *gocui.View) error {
+	if searchfound {
+		searchend(v, Cinfo.Commands[searchpos])
+	} else {
+		searchend(v, searchsaved)
+	}
+	return nil
+}
+
+// Esc in the cmd view - Leave the search putting back what was on the prompt line
+func searchCancel(g *gocui.Gui, v *gocui.View) error {
+	if searching {
+		searchend(v, searchsaved)
+	}
+	return nil
+}
+
+// Editor for the cmd view, while searching typing goes into the search
+func cmdEditor(v *gocui.View, key gocui.Key, ch rune, mod gocui.Modifier) {
+	if !searching {
+		gocui.DefaultEditor.Edit(v, key, ch, mod)
+		return
+	}
+	switch {
+	case ch != 0 && mod == 0:
+		searchType(v, ch)
+	case key == gocui.KeySpace:
+		searchType(v, ' ')
+	}
+}
@@ -0,0 +1,398 @@
+// Colour specs, attributes and colours by name, 256 colour index or #rrggbb, shown as near as the terminal can
+
+package screen
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jroimartin/gocui"
+)
+
+// Depth - How many colours the terminal can show
+type Depth int
+
+// The colour depths
+const (
+	Depth8   Depth = iota // The 8 ANSI colours, bold for the bright ones
+	Depth256              // xterm's 256 colours
+	DepthRGB              // 24 bit colour, gocui shows at most 256 so only for headless output
+)
+
+// Colours the output can show, set it with SetDepth before printing
+var depth = Depth8
+
+// TermDepth - The colours the terminal says it can show in $COLORTERM and $TERM
+func TermDepth() Depth {
+	colorterm := os.Getenv("COLORTERM")
+	switch {
+	case colorterm == "truecolor" || colorterm == "24bit":
+		return DepthRGB
+	case colorterm != "" || strings.Contains(os.Getenv("TERM"), "256color"):
+		return Depth256
+	}
+	return Depth8
+}
+
+// SetDepth - Show colours as near as d colours can, call it before anything is printed
+func SetDepth(d Depth) {
+	depth = d
+}
+
+// OutputMode - The gocui output mode for the depth
+func OutputMode() gocui.OutputMode {
+	if depth >= Depth256 {
+		return gocui.Output256
+	}
+	return gocui.OutputNormal
+}
+
+// The 16 ANSI colours as xterm shows them
+var ansirgb = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0}, {0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0}, {92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// Numbers of the colour names
+var colournumbers = map[string]int{
+	"black": 0, "red": 1, "green": 2, "yellow": 3, "blue": 4, "magenta": 5, "cyan": 6, "white": 7,
+}
+
+// colour - A colour by name or number (0-255) or #rrggbb
+type colour struct {
+	index int    // 256 colour number, -1 for rgb
+	rgb   [3]int // When index is -1
+}
+
+// Parse a colour name, 256 colour number or #rrggbb
+func parsecolour(s string) (colour, error) {
+	if n, ok := colournumbers[s]; ok {
+		return colour{index: n}, nil
+	}
+	if strings.HasPrefix(s, "#") {
+		v, err := strconv.ParseUint(s[1:], 16, 32)
+		if err != nil || len(s) != 7 {
+			return colour{}, fmt.Errorf("invalid colour %q, want #rrggbb", s)
+		}
+		return colour{index: -1, rgb: [3]int{int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff)}}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > 255 {
+		return colour{}, fmt.Errorf("invalid colour %q, want black, red, green, yellow, blue, magenta, cyan, white, 0-255 or #rrggbb", s)
+	}
+	return colour{index: n}, nil
+}
+
+// The red, green and blue of 256 colour number n
+func palette(n int) [3]int {
+	levels := [6]int{0, 95, 135, 175, 215, 255}
+	switch {
+	case n < 16:
+		return ansirgb[n]
+	case n < 232: // 6x6x6 cube
+		n -= 16
+		return [3]int{levels[n/36], levels[n/6%6], levels[n%6]}
+	default: // Greys
+		grey := 8 + 10*(n-232)
+		return [3]int{grey, grey, grey}
+	}
+}
+
+// Is rgb a grey, or near enough that it should not turn into a colour
+func grey(rgb [3]int) bool {
+	return max(rgb[0], rgb[1], rgb[2])-min(rgb[0], rgb[1], rgb[2]) < 32
+}
+
+// The nearest of the first n 256 colour numbers to rgb, a grey is only matched with greys
+func nearest(rgb [3]int, n int) int {
+	best, bestd := 0, -1
+	for i := 0; i < n; i++ {
+		p := palette(i)
+		if grey(rgb) && !grey(p) {
+			continue
+		}
+		d := 0
+		for j := range p {
+			d += (p[j] - rgb[j]) * (p[j] - rgb[j])
+		}
+		if bestd < 0 || d < bestd {
+			best, bestd = i, d
+		}
+	}
+	return best
+}
+
+func (c colour) colour() [3]int {
+	if c.index < 0 {
+		return c.rgb
+	}
+	return palette(c.index)
+}
+
+// The SGR parameters for the colour as foreground, or background if bg, at the depth
+func (c colour) sgr(bg bool, d Depth) string {
+	base := 30
+	if bg {
+		base = 40
+	}
+	switch {
+	case c.index >= 0 && c.index < 8:
+		return strconv.Itoa(base + c.index)
+	case c.index < 0 && d == DepthRGB:
+		return fmt.Sprintf("%d;2;%d;%d;%d", base+8, c.rgb[0], c.rgb[1], c.rgb[2])
+	case d >= Depth256 && c.index < 0:
+		return fmt.Sprintf("%d;5;%d", base+8, nearest(c.rgb, 256))
+	case d >= Depth256:
+		return fmt.Sprintf("%d;5;%d", base+8, c.index)
+	case bg: // Backgrounds have no bright
+		return strconv.Itoa(base + nearest(c.colour(), 8))
+	}
+	if n := nearest(c.colour(), 16); n >= 8 {
+		return strconv.Itoa(base+n-8) + ";1"
+	}
+	return strconv.Itoa(base + nearest(c.colour(), 8))
+}
+
+// The gocui attribute for the colour at the depth, gocui shows at most 256
+func (c colour) attribute(bg bool, d Depth) gocui.Attribute {
+	switch {
+	case c.index >= 0 && c.index < 8:
+		return gocui.Attribute(c.index + 1)
+	case d >= Depth256 && c.index < 0:
+		return gocui.Attribute(nearest(c.rgb, 256) + 1)
+	case d >= Depth256:
+		return gocui.Attribute(c.index + 1)
+	case bg:
+		return gocui.Attribute(nearest(c.colour(), 8) + 1)
+	}
+	if n := nearest(c.colour(), 16); n >= 8 {
+		return gocui.Attribute(n-8+1) | gocui.AttrBold
+	}
+	return gocui.Attribute(nearest(c.colour(), 8) + 1)
+}
+
+// attrs - Text attributes of a colour spec, gocui only shows bold, underline and reverse
+type attrs uint8
+
+// The attributes
+const (
+	bold attrs = 1 << iota
+	dim
+	italic
+	underline
+	reverse
+	blink
+)
+
+// The attribute names, their SGR parameters and gocui attributes
+var attrnames = []struct {
+	name  string
+	a     attrs
+	sgr   string
+	gocui gocui.Attribute
+}{
+	{"bold", bold, "1", gocui.AttrBold},
+	{"dim", dim, "2", 0},
+	{"italic", italic, "3", 0},
+	{"underline", underline, "4", gocui.AttrUnderline},
+	{"reverse", reverse, "7", gocui.AttrReverse},
+	{"blink", blink, "5", 0},
+}
+
+// Attribute called name
+func attrbyname(name string) (attrs, error) {
+	var names []string
+
+	for _, a := range attrnames {
+		if a.name == name {
+			return a.a, nil
+		}
+		names = append(names, a.name)
+	}
+	return 0, fmt.Errorf("unknown attribute %q, want %s", name, strings.Join(names, ", "))
+}
+
+// spec - A colour spec, [attribute,...:]foreground[/background]
+type spec struct {
+	attrs  attrs
+	fg, bg colour
+}
+
+// Parse a colour spec e.g. bold,underline:red/black or 208/#1c1c1c, the background defaults to black
+func parsespec(s string) (spec, error) {
+	var sp spec
+	var err error
+
+	if strings.Contains(s, "_") {
+		return parseold(s)
+	}
+	names, colours, ok := strings.Cut(s, ":")
+	if !ok {
+		colours = s
+	}
+	for _, name := range strings.Split(names, ",") {
+		if !ok {
+			break
+		}
+		a, err := attrbyname(strings.TrimSpace(name))
+		if err != nil {
+			return spec{}, err
+		}
+		sp.attrs |= a
+	}
+	f, b, ok := strings.Cut(colours, "/")
+	if f == "" {
+		return spec{}, fmt.Errorf("no foreground colour in %q, want [attribute,...:]foreground[/background]", s)
+	}
+	if !ok {
+		b = "black"
+	}
+	if sp.fg, err = parsecolour(f); err != nil {
+		return spec{}, err
+	}
+	if sp.bg, err = parsecolour(b); err != nil {
+		return spec{}, err
+	}
+	return sp, nil
+}
+
+// ValidColour - nil if colour is a style or a colour spec, otherwise what is wrong with it.
+// Check colours that come from outside the code with it, invalid ones print as errors.
+func ValidColour(colour string) error {
+	if _, ok := stylecolour(colour); ok || colour == "" || colour == "none" || colour == "off" {
+		return nil
+	}
+	_, err := parsespec(colour)
+	return err
+}
+
+// Where invalid colours are reported and those that have been, each is only reported once
+var reportMu sync.Mutex
+var report io.Writer
+var reported = map[string]bool{}
+
+// SetReport - Report invalid colours to w e.g. the err view
+func SetReport(w io.Writer) {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	report = w
+}
+
+// The spec of colour, if it is invalid that of the error style after reporting it the first time
+func colourspec(colour string) spec {
+	sp, err := parsespec(colour)
+	if err == nil {
+		return sp
+	}
+	reportMu.Lock()
+	if !reported[colour] && report != nil {
+		fmt.Fprintf(report, "Invalid colour %q: %s\n", colour, err)
+	}
+	reported[colour] = true
+	reportMu.Unlock()
+	errcolour, _ := stylecolour("error")
+	sp, _ = parsespec(errcolour) // Themes are checked when they are loaded
+	return sp
+}
+
+// Parse the old fg_bg colours, bold with a u (underline) or i (inverse) in front of fg,
+// a b in front of bg never did make it bright so it is ignored
+func parseold(s string) (spec, error) {
+	var err error
+
+	f, b, _ := strings.Cut(s, "_")
+	sp := spec{attrs: bold}
+	if len(f) > 1 && (f[0] == 'u' || f[0] == 'i') {
+		if f[0] == 'u' {
+			sp.attrs |= underline
+		} else {
+			sp.attrs |= reverse
+		}
+		f = f[1:]
+	}
+	if _, ok := colournumbers[b]; !ok && strings.HasPrefix(b, "b") {
+		b = b[1:]
+	}
+	if sp.fg, err = parsecolour(f); err != nil {
+		return spec{}, err
+	}
+	if sp.bg, err = parsecolour(b); err != nil {
+		return spec{}, err
+	}
+	return sp, nil
+}
+
+// The escape sequences for the spec at the depth, gocui only reads one 256 colour from a
+// sequence and the attributes after it so the foreground and attributes get one and the background another
+func (sp spec) sequence(d Depth) string {
+	f := sp.fg.sgr(false, d)
+	for _, a := range attrnames {
+		if sp.attrs&a.a != 0 && !(a.a == bold && strings.HasSuffix(f, ";1")) { // Bright is already bold
+			f += ansiseparator + a.sgr
+		}
+	}
+	return ansiprefix + f + ansipostfix + ansiprefix + sp.bg.sgr(true, d) + ansipostfix
+}
+
+// The gocui foreground, with the attributes, and background of the spec at the depth
+func (sp spec) attributes(d Depth) (gocui.Attribute, gocui.Attribute) {
+	return sp.fg.attribute(false, d) | sp.attrs.attribute(), sp.bg.attribute(true, d)
+}
+
+// The gocui attributes of a
+func (a attrs) attribute() gocui.Attribute {
+	var attr gocui.Attribute
+
+	for _, n := range attrnames {
+		if a&n.a != 0 {
+			attr |= n.gocui
+		}
+	}
+	return attr
+}
+
+// The escape sequence for just the attributes a, "" for none
+func (a attrs) sequence() string {
+	var params []string
+
+	for _, n := range attrnames {
+		if a&n.a != 0 {
+			params = append(params, n.sgr)
+		}
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return ansiprefix + strings.Join(params, ansiseparator) + ansipostfix
+}
+
+// No colours, from $NO_COLOR or -no-color, set it with SetNoColour before printing
+var nocolour bool
+
+// SetNoColour - Print without colours, errors and warnings stand out with a prefix and bold or reverse instead
+func SetNoColour(on bool) {
+	nocolour = on
+}
+
+// How styles stand out without colours
+var monostyles = map[string]struct {
+	prefix string
+	attrs  attrs
+}{
+	"error":     {"ERROR: ", reverse},
+	"warning":   {"WARNING: ", bold},
+	"title":     {"", bold},
+	"selection": {"", reverse},
+}
+
+// Prefix - What goes in front of each line printed in the style e.g. "ERROR: " without colours, otherwise ""
+func Prefix(style string) string {
+	if !nocolour {
+		return ""
+	}
+	return monostyles[style].prefix
+}
@@ -0,0 +1,200 @@
+package screen
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jroimartin/gocui"
+)
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		spec  string
+		attrs attrs
+		fg    colour
+		bg    colour
+	}{
+		{"red", 0, colour{index: 1}, colour{index: 0}},
+		{"red/white", 0, colour{index: 1}, colour{index: 7}},
+		{"bold:red/black", bold, colour{index: 1}, colour{index: 0}},
+		{"bold,underline:red", bold | underline, colour{index: 1}, colour{index: 0}},
+		{" bold , blink :cyan", bold | blink, colour{index: 6}, colour{index: 0}},
+		{"dim,italic,reverse:0/255", dim | italic | reverse, colour{index: 0}, colour{index: 255}},
+		{"208/#1c1c1c", 0, colour{index: 208}, colour{index: -1, rgb: [3]int{0x1c, 0x1c, 0x1c}}},
+		{"#FF8700", 0, colour{index: -1, rgb: [3]int{255, 135, 0}}, colour{index: 0}},
+		{"#000000/#ffffff", 0, colour{index: -1}, colour{index: -1, rgb: [3]int{255, 255, 255}}},
+		// The old fg_bg, always bold
+		{"red_black", bold, colour{index: 1}, colour{index: 0}},
+		{"ured_black", bold | underline, colour{index: 1}, colour{index: 0}},
+		{"iwhite_blue", bold | reverse, colour{index: 7}, colour{index: 4}},
+		{"green_bblack", bold, colour{index: 2}, colour{index: 0}},
+		{"yellow_blue", bold, colour{index: 3}, colour{index: 4}},
+		{"208_#1c1c1c", bold, colour{index: 208}, colour{index: -1, rgb: [3]int{0x1c, 0x1c, 0x1c}}},
+	}
+	for _, tt := range tests {
+		sp, err := parsespec(tt.spec)
+		if err != nil {
+			t.Errorf("parsespec(%q): %s", tt.spec, err)
+			continue
+		}
+		if sp.attrs != tt.attrs || sp.fg != tt.fg || sp.bg != tt.bg {
+			t.Errorf("parsespec(%q) = %+v, want %+v", tt.spec, sp, spec{tt.attrs, tt.fg, tt.bg})
+		}
+	}
+}
+
+func TestParseSpecErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		err  string
+	}{
+		{"", "no foreground colour"},
+		{"bold:", "no foreground colour"},
+		{"bold:/red", "no foreground colour"},
+		{"pink", `invalid colour "pink"`},
+		{"red/pink", `invalid colour "pink"`},
+		{"256", `invalid colour "256"`},
+		{"-1", `invalid colour "-1"`},
+		{"#12345", `invalid colour "#12345", want #rrggbb`},
+		{"#1234567", `invalid colour "#1234567", want #rrggbb`},
+		{"#gggggg", `invalid colour "#gggggg", want #rrggbb`},
+		{"#", `invalid colour "#", want #rrggbb`},
+		{"#-12345", `invalid colour "#-12345", want #rrggbb`},
+		{"loud:red", `unknown attribute "loud"`},
+		{":red", `unknown attribute ""`},
+		{"bold,,underline:red", `unknown attribute ""`},
+		{"red_pink", `invalid colour "pink"`},
+		{"pink_black", `invalid colour "pink"`},
+		{"u_black", `invalid colour "u"`},
+	}
+	for _, tt := range tests {
+		_, err := parsespec(tt.spec)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parsespec(%q) error %v, want %q", tt.spec, err, tt.err)
+		}
+	}
+}
+
+func TestNearest(t *testing.T) {
+	tests := []struct {
+		rgb  [3]int
+		n    int
+		want int
+	}{
+		{[3]int{0, 0, 0}, 256, 0},
+		{[3]int{255, 255, 255}, 256, 15},
+		{[3]int{255, 135, 0}, 256, 208},      // In the cube
+		{[3]int{0x1c, 0x1c, 0x1c}, 256, 234}, // A grey
+		{[3]int{255, 135, 0}, 16, 3},         // Orange is nearest yellow
+		{[3]int{255, 135, 0}, 8, 3},
+		{[3]int{200, 10, 10}, 8, 1},
+		{[3]int{130, 130, 130}, 16, 8},
+		{[3]int{130, 130, 130}, 8, 7}, // Greys stay grey
+		{[3]int{60, 60, 60}, 8, 0},
+		{[3]int{100, 90, 110}, 16, 8},
+	}
+	for _, tt := range tests {
+		if got := nearest(tt.rgb, tt.n); got != tt.want {
+			t.Errorf("nearest(%v, %d) = %d, want %d", tt.rgb, tt.n, got, tt.want)
+		}
+	}
+	for n := 0; n < 256; n++ { // Each colour is nearest itself
+		if got := nearest(palette(n), 256); palette(got) != palette(n) {
+			t.Errorf("nearest(palette(%d)) = %d", n, got)
+		}
+	}
+}
+
+func TestSequence(t *testing.T) {
+	tests := []struct {
+		spec  string
+		depth Depth
+		want  string
+	}{
+		{"red", Depth8, "\033[31m\033[40m"},
+		{"bold:red/black", Depth8, "\033[31;1m\033[40m"},
+		{"red_black", Depth256, "\033[31;1m\033[40m"},
+		{"ured_bblack", Depth8, "\033[31;1;4m\033[40m"},
+		{"bold,dim,italic,underline,reverse,blink:red/white", Depth8, "\033[31;1;2;3;4;7;5m\033[47m"},
+		// 256 colours, the foreground and its attributes then the background as gocui reads them
+		{"208", Depth256, "\033[38;5;208m\033[40m"},
+		{"underline:208/16", Depth256, "\033[38;5;208;4m\033[48;5;16m"},
+		{"#ff8700/#1c1c1c", Depth256, "\033[38;5;208m\033[48;5;234m"},
+		{"#ff8700/#1c1c1c", DepthRGB, "\033[38;2;255;135;0m\033[48;2;28;28;28m"},
+		{"208/16", DepthRGB, "\033[38;5;208m\033[48;5;16m"},
+		// Down to 8 colours, bold for the bright ones and backgrounds have none
+		{"9", Depth8, "\033[31;1m\033[40m"},
+		{"bold:9", Depth8, "\033[31;1m\033[40m"},
+		{"1", Depth8, "\033[31m\033[40m"},
+		{"196/17", Depth8, "\033[31;1m\033[40m"},
+		{"#ff8700/#cd0000", Depth8, "\033[33m\033[41m"},
+		{"#808080/#eeeeee", Depth8, "\033[30;1m\033[47m"},
+		{"232/255", Depth8, "\033[30m\033[47m"},
+	}
+	for _, tt := range tests {
+		sp, err := parsespec(tt.spec)
+		if err != nil {
+			t.Errorf("parsespec(%q): %s", tt.spec, err)
+			continue
+		}
+		if got := sp.sequence(tt.depth); got != tt.want {
+			t.Errorf("%q at depth %d = %q, want %q", tt.spec, tt.depth, got, tt.want)
+		}
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	tests := []struct {
+		spec   string
+		depth  Depth
+		fg, bg gocui.Attribute
+	}{
+		{"red/green", Depth8, gocui.ColorRed, gocui.ColorGreen},
+		{"bold,underline,reverse,dim,italic,blink:red", Depth8, gocui.ColorRed | gocui.AttrBold | gocui.AttrUnderline | gocui.AttrReverse, gocui.ColorBlack},
+		{"208/#000000", Depth256, 209, 1},
+		{"#ff8700/16", Depth256, 209, 17},
+		{"196/17", Depth8, gocui.ColorRed | gocui.AttrBold, gocui.ColorBlack},
+		{"ured_bwhite", Depth8, gocui.ColorRed | gocui.AttrBold | gocui.AttrUnderline, gocui.ColorWhite},
+	}
+	for _, tt := range tests {
+		sp, err := parsespec(tt.spec)
+		if err != nil {
+			t.Errorf("parsespec(%q): %s", tt.spec, err)
+			continue
+		}
+		if fg, bg := sp.attributes(tt.depth); fg != tt.fg || bg != tt.bg {
+			t.Errorf("%q at depth %d = %v %v, want %v %v", tt.spec, tt.depth, fg, bg, tt.fg, tt.bg)
+		}
+	}
+}
+
+func TestValidColour(t *testing.T) {
+	for _, c := range []string{"", "none", "off", "error", "packet-rx", "bold:red", "208/#1c1c1c", "red_black"} {
+		if err := ValidColour(c); err != nil {
+			t.Errorf("ValidColour(%q): %s", c, err)
+		}
+	}
+	for _, c := range []string{"errors", "pink", "loud:red"} {
+		if err := ValidColour(c); err == nil {
+			t.Errorf("ValidColour(%q) is nil", c)
+		}
+	}
+}
+
+// An invalid colour prints as an error and is reported the first time
+func TestInvalidColour(t *testing.T) {
+	var report, out bytes.Buffer
+
+	SetReport(&report)
+	defer SetReport(nil)
+	Fprintln(&out, "nosuch:red", "one")
+	Fprintln(&out, "nosuch:red", "two")
+	want := setcolour("error") + "one" + ansioff + "\n" + setcolour("error") + "two" + ansioff + "\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+	if got := strings.Count(report.String(), "\n"); got != 1 || !strings.Contains(report.String(), `"nosuch:red"`) {
+		t.Errorf("reported %q, want it once", report.String())
+	}
+}
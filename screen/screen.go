@@ -5,7 +5,9 @@ package screen
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"regexp"
 	"strings"
 	"sync"
 
@@ -18,58 +20,8 @@ var ansipostfix = "m"
 var ansiseparator = ";"
 var ansioff = "\033[0m" // Turn ansii escape sequence off
 
-// Foreground colours (b=bright)
-var fg = map[string]string{
-	// Normal
-	"black":   "30;1",
-	"red":     "31;1",
-	"green":   "32;1",
-	"yellow":  "33;1",
-	"blue":    "34;1",
-	"magenta": "35;1",
-	"cyan":    "36;1",
-	"white":   "37;1",
-	// Underlined
-	"ublack":   "30;4",
-	"ured":     "31;4",
-	"ugreen":   "32;4",
-	"uyellow":  "33;4",
-	"ublue":    "34;4",
-	"umagenta": "35;4",
-	"ucyan":    "36;4",
-	"uwhite":   "37;4",
-	// Invert
-	"iblack":   "30;7",
-	"ired":     "31;7",
-	"igreen":   "32;7",
-	"iyellow":  "33;7",
-	"iblue":    "34;7",
-	"imagenta": "35;7",
-	"icyan":    "36;7",
-	"iwhite":   "37;7",
-}
-
-// Background Colours (b=bright)
-var bg = map[string]string{
-	// Normal background
-	"black":   "40;1",
-	"red":     "41;1",
-	"green":   "42;1",
-	"yellow":  "43;1",
-	"blue":    "44;1",
-	"magenta": "45;1",
-	"cyan":    "46;1",
-	"white":   "47;1",
-	// Bright background (actually this just makes foreground lighter)
-	"bblack":   "40;4",
-	"bred":     "41;4",
-	"bgreen":   "42;4",
-	"byellow":  "43;4",
-	"bblue":    "44;4",
-	"bmagenta": "45;4",
-	"bcyan":    "46;4",
-	"bwhite":   "47;4",
-}
+// Views - Names of the views we print to
+var Views = []string{"cmd", "msg", "err", "packet"}
 
 // Ensure multiple prints to View don't interfere with eachother
 var ViewMu sync.Mutex
@@ -83,38 +35,30 @@ type Cmdinfo struct {
 	Numlines int      // How many lines do we have
 }
 
-// Create ansi sequence for colour change with c format of fg_bg (e.g. red_black)
+// Create ansi sequence for colour change with a colour spec (e.g. bold:red/black) or a style (e.g. error),
+// an invalid one is reported and shown as an error. Without colours only what makes a style stand out e.g. reverse for errors
 func setcolour(colour string) string {
 	if colour == "none" || colour == "" {
 		return ""
 	}
+	style := colour
+	if c, ok := stylecolour(colour); ok {
+		colour = c
+	}
 	if colour == "off" {
 		return ansioff
 	}
-	sequence := strings.Split(colour, "_")
-
-	switch len(sequence) {
-	case 2: // fg & bg
-		if fg[sequence[0]] != "" && bg[sequence[1]] != "" {
-			return ansiprefix + fg[sequence[0]] + ansiseparator + bg[sequence[1]] + ansipostfix
-		}
-		return ansiprefix + fg["white"] + ansiseparator + bg["red"] + ansipostfix
-	case 1: // fg and "black" bg
-		if fg[sequence[0]] != "" {
-			return ansiprefix + fg[sequence[0]] + ansiseparator + bg["black"] + ansipostfix
-		}
-		return ansiprefix + fg[sequence[0]] + ansiseparator + bg[sequence[1]] + ansipostfix
-	default: // Woops wrong fg or bg so scream at the screen
-		// Error so make it jump out at us
-		return ansiprefix + fg["white"] + ansiseparator + bg["bred"] + ansipostfix
+	sp := colourspec(colour)
+	if nocolour {
+		return monostyles[style].attrs.sequence()
 	}
+	return sp.sequence(depth)
 }
 
 // fprintf out in ANSII escape sequence in colour to view
-// If colour is undefined then still print it out but in bright red to show there is an issue
 func fprintf(g *gocui.Gui, vname string, colour string, format string, args ...interface{}) {
-
-	g.Update(func(g *gocui.Gui) error {
+	s := colourlines(colour, fmt.Sprintf(format, args...))
+	update(g, func(g *gocui.Gui) error {
 		ViewMu.Lock()
 		defer ViewMu.Unlock()
 		v, err := g.View(vname)
@@ -122,21 +66,15 @@ func fprintf(g *gocui.Gui, vname string, colour string, format string, args ...i
 			e := fmt.Sprintf("\nView Fprintf invalid view: %s", vname)
 			log.Fatal(e)
 		}
-		s := setcolour(colour)
-		s += fmt.Sprintf(format, args...)
-		if colour != "" {
-			s += setcolour("off")
-		}
 		fmt.Fprint(v, s)
 		return nil
 	})
 }
 
 // Fprintln out in ANSII escape sequence in colour to view
-// If colour is undefined then still print it out but in bright red to show there is an issue
 func fprintln(g *gocui.Gui, vname string, colour string, args ...interface{}) {
-
-	g.Update(func(g *gocui.Gui) error {
+	s := colourlines(colour, fmt.Sprint(args...))
+	update(g, func(g *gocui.Gui) error {
 		ViewMu.Lock()
 		defer ViewMu.Unlock()
 		v, err := g.View(vname)
@@ -144,16 +82,70 @@ func fprintln(g *gocui.Gui, vname string, colour string, args ...interface{}) {
 			e := fmt.Sprintf("\nView Fprintln invalid view: %s", vname)
 			log.Fatal(e)
 		}
-		s := setcolour(colour)
-		s += fmt.Sprint(args...)
-		if colour != "" {
-			s += setcolour("off")
-		}
 		fmt.Fprintln(v, s)
 		return nil
 	})
 }
 
+// Updates to the views in the order they were made, waiting for Flush
+var queueMu sync.Mutex
+var flushing bool // A Flush has been handed to g.Update and has not emptied the queue yet
+var queue []func(*gocui.Gui) error
+
+// How the updates get to the MainLoop, a variable so the tests can run them
+var guiupdate = (*gocui.Gui).Update
+
+// Flush - Run the queued updates to the views in the order they were made
+func Flush(g *gocui.Gui) error {
+	for {
+		queueMu.Lock()
+		if len(queue) == 0 {
+			flushing = false
+			queueMu.Unlock()
+			return nil
+		}
+		f := queue[0]
+		queue = queue[1:]
+		queueMu.Unlock()
+		if err := f(g); err != nil {
+			return err
+		}
+	}
+}
+
+// Run f from the gocui MainLoop after the updates made before it. g.Update starts a goroutine
+// for each function so they can run in any order, instead the updates are queued and one Flush
+// at a time is handed to it. Without a gui there is nowhere to show it
+func update(g *gocui.Gui, f func(*gocui.Gui) error) {
+	if g == nil {
+		return
+	}
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	queue = append(queue, f)
+	if !flushing {
+		flushing = true
+		guiupdate(g, Flush)
+	}
+}
+
+// plainWriter - Strips the colour escape sequences before writing to w
+type plainWriter struct {
+	w io.Writer
+}
+
+func (p plainWriter) Write(b []byte) (int, error) {
+	if _, err := io.WriteString(p.w, Uncolour(string(b))); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Plain - Writer that strips the colour escape sequences then writes to w, e.g. for files
+func Plain(w io.Writer) io.Writer {
+	return plainWriter{w: w}
+}
+
 // Send formatted output to "msg"  window
 func MsgPrintf(g *gocui.Gui, colour string, format string, args ...interface{}) {
 	fprintf(g, "msg", colour, format, args...)
@@ -193,3 +185,136 @@ func PacketPrintf(g *gocui.Gui, colour string, format string, args ...interface{
 func PacketPrintln(g *gocui.Gui, colour string, args ...interface{}) {
 	fprintln(g, "packet", colour, args...)
 }
+
+// Colour each line of s on its own so the output can be split into lines and still be in colour,
+// without colours errors and warnings get their prefix on each line
+func colourlines(colour string, s string) string {
+	on, off, prefix := setcolour(colour), "", Prefix(colour)
+	if on == "" && prefix == "" {
+		return s
+	}
+	if on != "" {
+		off = setcolour("off")
+	}
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		if lines[i] != "" {
+			lines[i] = on + prefix + lines[i] + off
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Colour - s in colour without the ERROR: or WARNING: prefixes that messages get, e.g. for the prompt line
+func Colour(colour string, s string) string {
+	on := setcolour(colour)
+	if on == "" || s == "" {
+		return s
+	}
+	return on + s + setcolour("off")
+}
+
+// Fprintf - Formatted output in colour to w (e.g. a command's output)
+func Fprintf(w io.Writer, colour string, format string, args ...interface{}) {
+	fmt.Fprint(w, colourlines(colour, fmt.Sprintf(format, args...)))
+}
+
+// Fprintln - Unformatted output in colour to w (e.g. a command's output)
+func Fprintln(w io.Writer, colour string, args ...interface{}) {
+	fmt.Fprintln(w, colourlines(colour, fmt.Sprint(args...)))
+}
+
+// Match the ANSI escape sequences setcolour creates
+var ansire = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// Uncolour - Remove the ANSI colour escape sequences from s
+func Uncolour(s string) string {
+	return ansire.ReplaceAllString(s, "")
+}
+
+// viewWriter - Everything written to it goes to a view
+type viewWriter struct {
+	g     *gocui.Gui
+	vname string
+}
+
+func (w *viewWriter) Write(p []byte) (int, error) {
+	s := string(p)
+	update(w.g, func(g *gocui.Gui) error {
+		ViewMu.Lock()
+		defer ViewMu.Unlock()
+		v, err := g.View(w.vname)
+		if err != nil {
+			e := fmt.Sprintf("\nView Write invalid view: %s", w.vname)
+			log.Fatal(e)
+		}
+		fmt.Fprint(v, s)
+		return nil
+	})
+	return len(p), nil
+}
+
+// Writer - io.Writer sending its output to view vname
+func Writer(g *gocui.Gui, vname string) io.Writer {
+	return &viewWriter{g: g, vname: vname}
+}
+
+// ViewSink - Command output to the gocui views, it is thrown away when G is nil
+type ViewSink struct {
+	G *gocui.Gui
+}
+
+// Sink - Command output to g's views
+func Sink(g *gocui.Gui) *ViewSink {
+	return &ViewSink{G: g}
+}
+
+// MsgWriter - Writes to the "msg" view
+func (s *ViewSink) MsgWriter() io.Writer {
+	return Writer(s.G, "msg")
+}
+
+// ErrWriter - Writes to the "err" view
+func (s *ViewSink) ErrWriter() io.Writer {
+	return Writer(s.G, "err")
+}
+
+// PacketWriter - Writes to the "packet" view
+func (s *ViewSink) PacketWriter() io.Writer {
+	return Writer(s.G, "packet")
+}
+
+// Buffer - What is in view vname
+func (s *ViewSink) Buffer(vname string) (string, error) {
+	if s.G == nil {
+		return "", fmt.Errorf("no view %s without a gui", vname)
+	}
+	v, err := s.G.View(vname)
+	if err != nil {
+		return "", fmt.Errorf("no view %s", vname)
+	}
+	return v.Buffer(), nil
+}
+
+// Clear - Empty view vname, there is nothing to do without a gui
+func (s *ViewSink) Clear(vname string) error {
+	if s.G == nil {
+		return nil
+	}
+	if _, err := s.G.View(vname); err != nil {
+		return fmt.Errorf("no view %s", vname)
+	}
+	update(s.G, func(g *gocui.Gui) error {
+		ViewMu.Lock()
+		defer ViewMu.Unlock()
+		v, err := g.View(vname)
+		if err != nil {
+			return nil
+		}
+		v.Clear()
+		v.SetOrigin(0, 0)
+		v.SetCursor(0, 0)
+		return nil
+	})
+	return nil
+}
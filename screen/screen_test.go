@@ -0,0 +1,52 @@
+package screen
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jroimartin/gocui"
+)
+
+// What is written to a view arrives in the order it was written, even though g.Update runs
+// each function it is given from a goroutine of its own
+func TestUpdateOrder(t *testing.T) {
+	const n = 1000
+
+	g := &gocui.Gui{}
+	if _, err := g.SetView("msg", 0, 0, 80, 24); err != gocui.ErrUnknownView {
+		t.Fatal(err)
+	}
+	updates := make(chan func(*gocui.Gui) error)
+	defer func(u func(*gocui.Gui, func(*gocui.Gui) error)) { guiupdate = u }(guiupdate)
+	guiupdate = func(g *gocui.Gui, f func(*gocui.Gui) error) {
+		go func() { updates <- f }() // As gocui does
+	}
+	go func() {
+		w := Writer(g, "msg")
+		for i := 0; i < n; i++ {
+			if i%2 == 0 {
+				fmt.Fprintln(w, i)
+			} else {
+				MsgPrintln(g, "none", i)
+			}
+		}
+	}()
+	v, _ := g.View("msg")
+	for len(v.BufferLines()) <= n { // The last line is the empty one after the final newline
+		select {
+		case f := <-updates:
+			if err := f(g); err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("only %d of %d lines written", len(v.BufferLines())-1, n)
+		}
+	}
+	for i, line := range v.BufferLines()[:n] {
+		if line != strconv.Itoa(i) {
+			t.Fatalf("line %d is %q", i, line)
+		}
+	}
+}
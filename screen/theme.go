@@ -0,0 +1,195 @@
+// Themes - The colours of the styles (error, prompt...) used instead of naming colours
+
+package screen
+
+import (
+	"bufio"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jroimartin/gocui"
+)
+
+// Styles - What the styles are for, print with a style name rather than a colour e.g. "error"
+var Styles = map[string]string{
+	"error":     "Errors",
+	"warning":   "Warnings",
+	"info":      "Notices e.g. a job has started",
+	"output":    "What commands show",
+	"prompt":    "The command line prompt",
+	"debug":     "Debugging in the err view",
+	"packet-rx": "Packets received",
+	"packet-tx": "Packets sent",
+	"title":     "Headings",
+	"selection": "The current view's title when it is highlighted",
+	"cmd":       "The cmd view",
+	"msg":       "The msg view",
+	"err":       "The err view",
+	"packet":    "The packet view",
+}
+
+// Theme - The colour spec of each style e.g. "error" is "bold:red/black"
+type Theme map[string]string
+
+// The bundled themes, dark is the default and fills in what other themes leave out
+//
+//go:embed themes/*.theme
+var themefs embed.FS
+
+// Themedir - Where to find your own themes, name.theme,
+// defaults to $XDG_CONFIG_HOME/testgocui/themes (~/.config/testgocui/themes)
+var Themedir = defaultthemedir()
+
+func defaultthemedir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "testgocui", "themes")
+}
+
+// The theme in use and its name
+var themeMu sync.RWMutex
+var theme = mustbundled("dark")
+var themename = "dark"
+
+// Parse every bundled theme so a mistake in one panics at startup rather than when it is picked
+func init() {
+	bundled, _ := fs.Glob(themefs, "themes/*.theme")
+	for _, f := range bundled {
+		mustbundled(strings.TrimSuffix(filepath.Base(f), ".theme"))
+	}
+}
+
+// The bundled theme called name, they are checked at startup
+func mustbundled(name string) Theme {
+	t := Theme{}
+	f, err := themefs.Open("themes/" + name + ".theme")
+	if err == nil {
+		defer f.Close()
+		err = parsetheme(name+".theme", f, t)
+	}
+	if err != nil {
+		log.Panicf("screen: bundled theme %s: %s", name, err)
+	}
+	return t
+}
+
+// Read a theme, lines of style = colour, # comments
+func parsetheme(file string, r io.Reader, t Theme) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		style, colour, ok := strings.Cut(line, "=")
+		style, colour = strings.TrimSpace(style), strings.TrimSpace(colour)
+		switch {
+		case !ok:
+			return fmt.Errorf("%s:%d: want style = colour", file, n)
+		case Styles[style] == "":
+			return fmt.Errorf("%s:%d: unknown style %q", file, n, style)
+		}
+		if _, err := parsespec(colour); err != nil {
+			return fmt.Errorf("%s:%d: %s: %s", file, n, style, err)
+		}
+		t[style] = colour
+	}
+	return scanner.Err()
+}
+
+// LoadTheme - The theme called name from Themedir or bundled, or a file if name is a path.
+// Styles it leaves out are as in the dark theme.
+func LoadTheme(name string) (Theme, error) {
+	var f fs.File
+	var err error
+
+	t := mustbundled("dark")
+	file := name
+	if strings.ContainsRune(name, filepath.Separator) || strings.HasSuffix(name, ".theme") {
+		f, err = os.Open(file)
+	} else {
+		err = fs.ErrNotExist
+		if Themedir != "" {
+			file = filepath.Join(Themedir, name+".theme")
+			f, err = os.Open(file)
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			file = name + ".theme"
+			f, err = themefs.Open("themes/" + file)
+		}
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no theme %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return t, parsetheme(file, f, t)
+}
+
+// SetTheme - Use the theme called name from now on, see LoadTheme
+func SetTheme(name string) error {
+	t, err := LoadTheme(name)
+	if err != nil {
+		return err
+	}
+	themeMu.Lock()
+	defer themeMu.Unlock()
+	theme, themename = t, name
+	return nil
+}
+
+// ThemeName - Name of the theme in use
+func ThemeName() string {
+	themeMu.RLock()
+	defer themeMu.RUnlock()
+	return themename
+}
+
+// ThemeNames - Sorted names of the bundled themes and those in Themedir
+func ThemeNames() []string {
+	var names []string
+
+	bundled, _ := fs.Glob(themefs, "themes/*.theme")
+	yours, _ := filepath.Glob(filepath.Join(Themedir, "*.theme"))
+	seen := map[string]bool{}
+	for _, f := range append(bundled, yours...) {
+		name := strings.TrimSuffix(filepath.Base(f), ".theme")
+		if !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The colour of style in the theme, false if it is not a style
+func stylecolour(style string) (string, bool) {
+	themeMu.RLock()
+	defer themeMu.RUnlock()
+	colour, ok := theme[style]
+	return colour, ok
+}
+
+// ViewColours - The gocui foreground and background of a style, e.g. for a view's FgColor and BgColor
+func ViewColours(style string) (gocui.Attribute, gocui.Attribute) {
+	colour, _ := stylecolour(style)
+	sp := colourspec(colour)
+	if nocolour {
+		return gocui.ColorDefault | monostyles[style].attrs.attribute(), gocui.ColorDefault
+	}
+	return sp.attributes(depth)
+}
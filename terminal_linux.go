@@ -0,0 +1,6 @@
+package main
+
+import "syscall"
+
+// ioctl to read the termios settings
+const ioctlgettermios = syscall.TCGETS
@@ -0,0 +1,19 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+// Telling a terminal from a file, pipe or other device
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Is f a terminal rather than a file, pipe or another device such as /dev/null,
+// only a terminal has termios settings to read
+func isterminal(f *os.File) bool {
+	var t syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlgettermios, uintptr(unsafe.Pointer(&t)))
+	return errno == 0
+}
@@ -4,8 +4,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 
@@ -40,7 +42,7 @@ func gotolastrow(g *gocui.Gui, v *gocui.View) {
 	cx, cy := v.Cursor()
 
 	lines := len(v.BufferLines())
-	screen.ErrPrintf(g, "white_black", "gotolastrow ox=%d oy=%d cx=%d cy=%d blines=%d\n",
+	screen.ErrPrintf(g, "debug", "gotolastrow ox=%d oy=%d cx=%d cy=%d blines=%d\n",
 		oy, oy, cx, cy, lines)
 	// Don't move down if we already are at the last line in current views Bufferlines
 	if oy+cy == lines-1 {
@@ -84,6 +86,9 @@ func switchView(g *gocui.Gui, v *gocui.View) error {
 func backSpace(g *gocui.Gui, v *gocui.View) error {
 	switch v.Name() {
 	case "cmd":
+		if searching {
+			return searchBackspace(g, v)
+		}
 		cx, _ := v.Cursor()
 		if cx <= promptlen(Cinfo) { // Dont move we are at the prompt
 			return nil
@@ -100,13 +105,16 @@ func backSpace(g *gocui.Gui, v *gocui.View) error {
 func cursorLeft(g *gocui.Gui, v *gocui.View) error {
 	switch v.Name() {
 	case "cmd":
+		if searching {
+			return searchAccept(g, v)
+		}
 		cx, cy := v.Cursor()
 		if cx <= promptlen(Cinfo) { // Dont move we are at the prompt
 			return nil
 		}
 		// Move back a character
 		if err := v.SetCursor(cx-1, cy); err != nil {
-			screen.ErrPrintln(g, "white_black", v.Name(), "LeftArrow:", "cx=", cx, "cy=", cy, "error=", err)
+			screen.ErrPrintln(g, "debug", v.Name(), "LeftArrow:", "cx=", cx, "cy=", cy, "error=", err)
 		}
 	case "msg", "packet":
 		return nil
@@ -118,6 +126,9 @@ func cursorLeft(g *gocui.Gui, v *gocui.View) error {
 func cursorRight(g *gocui.Gui, v *gocui.View) error {
 	switch v.Name() {
 	case "cmd":
+		if searching {
+			return searchAccept(g, v)
+		}
 		cx, cy := v.Cursor()
 		line, _ := v.Line(cy)
 		if cx >= len(line)-1 { // We are at the end of line do nothing
@@ -126,7 +137,7 @@ func cursorRight(g *gocui.Gui, v *gocui.View) error {
 		}
 		// Move forward a character
 		if err := v.SetCursor(cx+1, cy); err != nil {
-			screen.ErrPrintln(g, "white_red", "RightArrow:", "cx=", cx, "cy=", cy, "error=", err)
+			screen.ErrPrintln(g, "error", "RightArrow:", "cx=", cx, "cy=", cy, "error=", err)
 		}
 	case "msg", "packet":
 		return nil
@@ -134,52 +145,74 @@ func cursorRight(g *gocui.Gui, v *gocui.View) error {
 	return nil
 }
 
-// Handle down cursor -- All good!
+// Handle down cursor, in the cmd view it moves forward through the history
 func cursorDown(g *gocui.Gui, v *gocui.View) error {
+	if v.Name() == "cmd" {
+		if searching {
+			return searchAccept(g, v)
+		}
+		return historyDown(g, v)
+	}
+	return scrollDown(g, v)
+}
+
+// Handle up cursor, in the cmd view it moves back through the history
+func cursorUp(g *gocui.Gui, v *gocui.View) error {
+	if v.Name() == "cmd" {
+		if searching {
+			return searchAccept(g, v)
+		}
+		return historyUp(g, v)
+	}
+	return scrollUp(g, v)
+}
+
+// Move the cursor down a line -- All good!
+func scrollDown(g *gocui.Gui, v *gocui.View) error {
 	ox, oy := v.Origin()
 	cx, cy := v.Cursor()
 	// Don't move down if we are at the last line in current views Bufferlines
 	if oy+cy == len(v.BufferLines())-1 {
-		screen.ErrPrintf(g, "white_black", "%s Down oy=%d cy=%d lines=%d\n",
+		screen.ErrPrintf(g, "debug", "%s Down oy=%d cy=%d lines=%d\n",
 			v.Name(), oy, cy, len(v.BufferLines()))
 		return nil
 	}
 	if err := v.SetCursor(cx, cy+1); err != nil {
-		screen.ErrPrintf(g, "magenta_black", "%s Down oy=%d cy=%d lines=%d err=%s\n",
+		screen.ErrPrintf(g, "debug", "%s Down oy=%d cy=%d lines=%d err=%s\n",
 			v.Name(), oy, cy, len(v.BufferLines()), err.Error())
 		// ox, oy = v.Origin()
 		if err := v.SetOrigin(ox, oy+1); err != nil {
-			screen.ErrPrintf(g, "cyan_black", "%s Down oy=%d cy=%d lines=%d err=%s\n",
+			screen.ErrPrintf(g, "debug", "%s Down oy=%d cy=%d lines=%d err=%s\n",
 				v.Name(), oy, cy, len(v.BufferLines()), err.Error())
 			return err
 		}
 	}
-	screen.ErrPrintf(g, "green_black", "%s Down oy=%d cy=%d lines=%d\n",
+	screen.ErrPrintf(g, "debug", "%s Down oy=%d cy=%d lines=%d\n",
 		v.Name(), oy, cy, len(v.BufferLines()))
 	return nil
 }
 
-// Why don't we scroll up!!!
-func cursorUp(g *gocui.Gui, v *gocui.View) error {
+// Move the cursor up a line - Why don't we scroll up!!!
+func scrollUp(g *gocui.Gui, v *gocui.View) error {
 	ox, oy := v.Origin()
 	cx, cy := v.Cursor()
-	screen.ErrPrintf(g, "green_black", "%s Up ox=%d oy=%d cx=%d cy=%d lines=%d\n",
+	screen.ErrPrintf(g, "debug", "%s Up ox=%d oy=%d cx=%d cy=%d lines=%d\n",
 		v.Name(), ox, oy, cx, cy, len(v.BufferLines()))
 	if err := v.SetCursor(cx, cy-1); err != nil && oy > 0 {
-		screen.ErrPrintf(g, "magenta_black", "%s SetCur Up oy=%d cy=%d lines=%d err=%s\n",
+		screen.ErrPrintf(g, "debug", "%s SetCur Up oy=%d cy=%d lines=%d err=%s\n",
 			v.Name(), oy, cy, len(v.BufferLines()), err.Error())
 		if err := v.SetOrigin(ox, oy-1); err != nil {
-			screen.ErrPrintf(g, "cyan_black", "%s SetOri Up oy=%d cy=%d lines=%d err=%s\n",
+			screen.ErrPrintf(g, "debug", "%s SetOri Up oy=%d cy=%d lines=%d err=%s\n",
 				v.Name(), oy-1, cy, len(v.BufferLines()), err.Error())
 			return err
 		} else {
-			screen.ErrPrintf(g, "red_black", "%s SetOri Up oy=%d cy=%d lines=%d\n",
+			screen.ErrPrintf(g, "error", "%s SetOri Up oy=%d cy=%d lines=%d\n",
 				v.Name(), oy, cy, len(v.BufferLines()))
 			return nil
 		}
 	}
 	_, cy = v.Cursor()
-	screen.ErrPrintf(g, "green_black", "%s Up oy=%d cy=%d lines=%d\n",
+	screen.ErrPrintf(g, "debug", "%s Up oy=%d cy=%d lines=%d\n",
 		v.Name(), oy, cy, len(v.BufferLines()))
 	return nil
 }
@@ -191,7 +224,10 @@ func getLine(g *gocui.Gui, v *gocui.View) error {
 	}
 	switch v.Name() {
 	case "cmd":
-		// c := &Cinfo
+		// Run what the history search found
+		if searching {
+			searchAccept(g, v)
+		}
 		// Find out where we are
 		_, cy := v.Cursor()
 		// Get the line
@@ -201,21 +237,25 @@ func getLine(g *gocui.Gui, v *gocui.View) error {
 		if command[1] == "" { // We have just hit enter - do nothing
 			return nil
 		}
+		// Replace !!, !n, !prefix and ^old^new with the commands from history
+		cmdline, err := Cinfo.Expand(command[1])
+		if err != nil {
+			screen.ErrPrintln(g, "error", err.Error())
+			prompt(g, v)
+			return nil
+		}
+		if cmdline != command[1] { // Show what we are really running
+			screen.MsgPrintln(g, "info", cmdline)
+		}
 		// Save the command into history
-		Cinfo.Commands = append(Cinfo.Commands, command[1])
-
-		// Spawn a go to run the command
-		go func(*gocui.Gui, string) {
-			// defer Sarwg.Done()
-			cli.Docmd(g, command[1], Cinfo)
-		}(g, command[1])
-
-		if command[1] == "exit" || command[1] == "quit" {
-			// Sarwg.Wait()
-			err := quit(g, v)
-			// THIS IS A KLUDGE FIX IT WITH A CHANNEL
-			log.Fatal("\nGocui Exit. Bye!\n", err)
+		if err := Cinfo.Add(cmdline); err != nil {
+			screen.ErrPrintln(g, "error", "History: ", err.Error())
 		}
+		historyReset()
+		interrupted = false
+
+		// Spawn a go routine to run the command, CtrlC will interrupt it
+		cli.Start(screen.Sink(g), cmdline, Cinfo)
 		prompt(g, v)
 	case "msg", "packet", "err":
 		return cursorDown(g, v)
@@ -223,10 +263,32 @@ func getLine(g *gocui.Gui, v *gocui.View) error {
 	return nil
 }
 
+// Cancel anything still running and leave the mainloop
 func quit(g *gocui.Gui, v *gocui.View) error {
+	cli.CancelAll()
 	return gocui.ErrQuit
 }
 
+// Set when CtrlC has interrupted a command, another CtrlC before the next command quits
+var interrupted bool = false
+
+// CtrlC - In the cmd view interrupt the most recent running command,
+// a second CtrlC or CtrlC with nothing running quits
+func ctrlC(g *gocui.Gui, v *gocui.View) error {
+	if v != nil && v.Name() == "cmd" && searching {
+		return searchCancel(g, v)
+	}
+	if v == nil || v.Name() != "cmd" || interrupted {
+		return quit(g, v)
+	}
+	if !cli.Interrupt() {
+		return quit(g, v)
+	}
+	interrupted = true
+	screen.MsgPrintln(g, "warning", "^C - CtrlC again to quit")
+	return nil
+}
+
 // ShowPacket - Show Packet trace info
 var showpacket bool = false
 
@@ -246,40 +308,99 @@ func showPacket(g *gocui.Gui, v *gocui.View) error {
 	return err
 }
 
-// Bind keys to function handlers
-func keybindings(g *gocui.Gui) error {
-	if err := g.SetKeybinding("", gocui.KeyCtrlSpace, gocui.ModNone, switchView); err != nil {
-		return err
-	}
-	if err := g.SetKeybinding("", gocui.KeyArrowDown, gocui.ModNone, cursorDown); err != nil {
-		return err
-	}
-	if err := g.SetKeybinding("", gocui.KeyArrowUp, gocui.ModNone, cursorUp); err != nil {
-		return err
-	}
-	if err := g.SetKeybinding("", gocui.KeyArrowLeft, gocui.ModNone, cursorLeft); err != nil {
-		return nil
-	}
-	if err := g.SetKeybinding("", gocui.KeyArrowRight, gocui.ModNone, cursorRight); err != nil {
-		return nil
-	}
-	if err := g.SetKeybinding("", gocui.KeyCtrlP, gocui.ModNone, showPacket); err != nil {
-		return nil
-	}
-	if err := g.SetKeybinding("", gocui.KeyCtrlC, gocui.ModNone, quit); err != nil {
-		return err
+// binding - Handler for a key in a view, "" for every view, and what it does for help
+type binding struct {
+	view    string
+	key     interface{} // gocui.Key or rune
+	handler func(*gocui.Gui, *gocui.View) error
+	help    string // Keys with the same help are shown together, "" for none
+}
+
+// Key bindings, the handlers for every binding of a key in the current view run
+var bindings = []binding{
+	{"", gocui.KeyCtrlSpace, switchView, "Rotate between views"},
+	{"cmd", gocui.KeyArrowUp, cursorUp, "Previous/next command in history"},
+	{"cmd", gocui.KeyArrowDown, cursorDown, "Previous/next command in history"},
+	{"msg", gocui.KeyArrowUp, cursorUp, "Move the cursor"},
+	{"msg", gocui.KeyArrowDown, cursorDown, "Move the cursor"},
+	{"err", gocui.KeyArrowUp, cursorUp, "Move the cursor"},
+	{"err", gocui.KeyArrowDown, cursorDown, "Move the cursor"},
+	{"packet", gocui.KeyArrowUp, cursorUp, "Move the cursor"},
+	{"packet", gocui.KeyArrowDown, cursorDown, "Move the cursor"},
+	{"", gocui.KeyArrowLeft, cursorLeft, "Move the cursor"},
+	{"", gocui.KeyArrowRight, cursorRight, "Move the cursor"},
+	{"", gocui.KeyCtrlP, showPacket, "Show/Hide Packet view"},
+	{"", gocui.KeyCtrlC, ctrlC, "Interrupt command, twice to quit"},
+	{"", gocui.KeyEnter, getLine, "Run the command line"},
+	{"", gocui.KeyBackspace, backSpace, "Delete back a character"},
+	{"", gocui.KeyBackspace2, backSpace, "Delete back a character"},
+	{"", gocui.KeyDelete, backSpace, "Delete back a character"},
+	{"cmd", gocui.KeyTab, complete, "Complete command or argument"},
+	{"cmd", gocui.KeyCtrlR, searchHistory, "Search command history"},
+	{"cmd", gocui.KeyEsc, searchCancel, "Cancel history search"},
+}
+
+// Names of the keys for help and the virtual terminal scripts e.g. <Enter>
+var keynames = map[string]gocui.Key{
+	"Enter":     gocui.KeyEnter,
+	"Tab":       gocui.KeyTab,
+	"Esc":       gocui.KeyEsc,
+	"Up":        gocui.KeyArrowUp,
+	"Down":      gocui.KeyArrowDown,
+	"Left":      gocui.KeyArrowLeft,
+	"Right":     gocui.KeyArrowRight,
+	"Backspace": gocui.KeyBackspace2,
+	"Delete":    gocui.KeyDelete,
+	"Space":     gocui.KeySpace,
+	"CtrlSpace": gocui.KeyCtrlSpace,
+	"CtrlC":     gocui.KeyCtrlC,
+	"CtrlP":     gocui.KeyCtrlP,
+	"CtrlR":     gocui.KeyCtrlR,
+}
+
+// Name of the key for help, the same as the virtual terminal scripts use e.g. CtrlP
+func keyname(key interface{}) string {
+	if ch, ok := key.(rune); ok {
+		return string(ch)
 	}
-	if err := g.SetKeybinding("", gocui.KeyEnter, gocui.ModNone, getLine); err != nil {
-		return err
+	if key == gocui.KeyBackspace { // Is CtrlH, most terminals send Backspace2
+		return "Backspace"
 	}
-	if err := g.SetKeybinding("", gocui.KeyBackspace, gocui.ModNone, backSpace); err != nil {
-		return nil
+	for name, k := range keynames {
+		if k == key {
+			return name
+		}
 	}
-	if err := g.SetKeybinding("", gocui.KeyBackspace2, gocui.ModNone, backSpace); err != nil {
-		return nil
+	return fmt.Sprint(key)
+}
+
+// What the keys do from the bindings, keys with the same help together e.g. Up/Down - Move the cursor
+func keyhelp() []cli.KeyHelp {
+	var keys []cli.KeyHelp
+
+	for _, b := range bindings {
+		if b.help == "" {
+			continue
+		}
+		i := 0
+		for i < len(keys) && keys[i].Help != b.help {
+			i++
+		}
+		if i == len(keys) {
+			keys = append(keys, cli.KeyHelp{Keys: keyname(b.key), Help: b.help})
+		} else if name := keyname(b.key); !strings.Contains("/"+keys[i].Keys+"/", "/"+name+"/") {
+			keys[i].Keys += "/" + name
+		}
 	}
-	if err := g.SetKeybinding("", gocui.KeyDelete, gocui.ModNone, backSpace); err != nil {
-		return nil
+	return keys
+}
+
+// Bind keys to function handlers
+func keybindings(g *gocui.Gui) error {
+	for _, b := range bindings {
+		if err := g.SetKeybinding(b.view, b.key, gocui.ModNone, b.handler); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -289,6 +410,11 @@ func promptlen(v cli.Cmdhist) int {
 	return len(v.Prompt) + len(strconv.Itoa(v.Curline)) + v.Ppad
 }
 
+// The prompt text for the current line e.g. testgocui[5]:
+func promptstr() string {
+	return fmt.Sprintf("%s[%d]:", Cinfo.Prompt, Cinfo.Curline)
+}
+
 // Display the prompt
 func prompt(g *gocui.Gui, v *gocui.View) {
 	if g == nil || v == nil || v.Name() != "cmd" {
@@ -299,18 +425,21 @@ func prompt(g *gocui.Gui, v *gocui.View) {
 	// Only display it if it is on the next new line
 	if oy+cy == Cinfo.Curline {
 		if FirstPass { // Just the prompt no precedin \n as we are the first line
-			screen.CmdPrintf(g, "yellow_black", "%s[%d]:", Cinfo.Prompt, Cinfo.Curline)
+			screen.CmdPrintf(g, "prompt", "%s", promptstr())
 			v.SetCursor(promptlen(Cinfo), cy)
 		} else { // End the last command by going to new lin \n then put up the new prompt
 			Cinfo.Curline++
-			screen.CmdPrintf(g, "yellow_black", "\n%s[%d]:", Cinfo.Prompt, Cinfo.Curline)
-			_, cy := v.Cursor()
-			v.SetCursor(promptlen(Cinfo), cy)
-			if err := cursorDown(g, v); err != nil {
-				screen.MsgPrintln(g, "red_black", "Cannot move to next line")
+			screen.CmdPrintf(g, "prompt", "\n%s", promptstr())
+			// The print is not in the view yet so go to where it will be, scrolling once the view is full
+			ox, oy := v.Origin()
+			_, sy := v.Size()
+			if Cinfo.Curline-oy >= sy {
+				oy = Cinfo.Curline - sy + 1
+				if err := v.SetOrigin(ox, oy); err != nil {
+					screen.MsgPrintln(g, "error", "Cannot move to next line")
+				}
 			}
-			_, cy = v.Cursor()
-			v.SetCursor(promptlen(Cinfo), cy+1)
+			v.SetCursor(promptlen(Cinfo), Cinfo.Curline-oy)
 		}
 	}
 }
@@ -345,12 +474,11 @@ func layout(g *gocui.Gui) error {
 		}
 		cmd.Title = "Command Line"
 		cmd.Highlight = false
-		cmd.BgColor = gocui.ColorBlack
-		cmd.FgColor = gocui.ColorGreen
 		cmd.Editable = true
 		cmd.Overwrite = true
 		cmd.Wrap = true
 		cmd.Autoscroll = false // This (false) enables vertical scrolling!
+		cmd.Editor = gocui.EditorFunc(cmdEditor)
 	}
 	// This is the error msg view -- mic errors go here
 	if cmd, err = g.SetView("err", maxx/2, maxy-(maxy/ratio)+1, maxx-1, maxy-1); err != nil {
@@ -359,8 +487,6 @@ func layout(g *gocui.Gui) error {
 		}
 		cmd.Title = "Errors"
 		cmd.Highlight = false
-		cmd.BgColor = gocui.ColorBlack
-		cmd.FgColor = gocui.ColorGreen
 		cmd.Editable = false
 		cmd.Overwrite = false
 		cmd.Wrap = true
@@ -374,8 +500,6 @@ func layout(g *gocui.Gui) error {
 		}
 		packet.Title = "Packets"
 		packet.Highlight = false
-		packet.BgColor = gocui.ColorBlack
-		packet.FgColor = gocui.ColorMagenta
 		packet.Editable = false
 		packet.Wrap = true
 		packet.Overwrite = false
@@ -389,14 +513,20 @@ func layout(g *gocui.Gui) error {
 		}
 		msg.Title = "Messages"
 		msg.Highlight = false
-		msg.BgColor = gocui.ColorBlack
-		msg.FgColor = gocui.ColorYellow
 		msg.Editable = false
 		msg.Wrap = true
 		msg.Overwrite = false
 		msg.Autoscroll = false // This (false) enables vertical scrolling!
 	}
 
+	// Colour the views from the theme every time as the theme command can change it
+	for _, name := range screen.Views {
+		if v, err := g.View(name); err == nil {
+			v.FgColor, v.BgColor = screen.ViewColours(name)
+		}
+	}
+	g.SelFgColor, g.SelBgColor = screen.ViewColours("selection")
+
 	// Display the prompt without the \n first time around
 	if FirstPass {
 		// All inputs happen via the cmd view and go there to start
@@ -405,15 +535,24 @@ func layout(g *gocui.Gui) error {
 		}
 		g.Cursor = true
 		g.Highlight = true
-		g.SelFgColor = gocui.ColorRed
-		g.SelBgColor = gocui.ColorWhite
 		cmd.SetCursor(0, 0)
 		Cinfo.Curline = 0
 		prompt(g, cmd)
 		FirstPass = false
-		screen.MsgPrintln(g, "white_black", "CtrlSpace - Rotate between views")
-		screen.MsgPrintln(g, "white_black", "CtrlP - Show/Hide Packet view")
-		screen.MsgPrintln(g, "white_black", "? for help")
+		// What the keys do, help shows it again
+		cli.Keys = keyhelp()
+		for _, k := range cli.Keys {
+			screen.MsgPrintln(g, "info", k.String())
+		}
+		screen.MsgPrintln(g, "info", "? for help")
+		// Run the startup commands now we have somewhere to show what they do, as if they were
+		// entered on line 0 so the job has a number of its own and the user starts on line 1
+		if _, err := os.Stat(cli.Rcfile); cli.Rcfile != "" && err == nil {
+			rc := "source " + cli.Quote(cli.Rcfile)
+			screen.CmdPrintf(g, "none", "%s", rc)
+			cli.Start(screen.Sink(g), rc, Cinfo)
+			prompt(g, cmd)
+		}
 	}
 	return nil
 }
@@ -421,17 +560,54 @@ func layout(g *gocui.Gui) error {
 // Main
 func main() {
 
+	flag.StringVar(&cli.Histfile, "history", cli.Histfile, "File to keep command history in, \"\" for none")
+	flag.IntVar(&cli.Histsize, "histsize", cli.Histsize, "Most commands to keep in history")
+	flag.StringVar(&cli.Rcfile, "rc", cli.Rcfile, "Commands to run at startup, \"\" for none")
+	flag.StringVar(&cli.Aliasfile, "aliases", cli.Aliasfile, "File to keep aliases in, \"\" for none")
+	flag.StringVar(&screen.Themedir, "themes", screen.Themedir, "Directory of your own themes, name.theme")
+	theme := flag.String("theme", "dark", "Colour theme, one of "+strings.Join(screen.ThemeNames(), ", ")+" or a file")
+	nocolour := flag.Bool("no-color", os.Getenv("NO_COLOR") != "", "No colours, errors and warnings have an ERROR: or WARNING: prefix (the default when $NO_COLOR is set)")
+	flag.BoolVar(&cli.Abbrev, "abbrev", cli.Abbrev, "Commands can be shortened to a prefix no other command has e.g. hel for help")
+	nogui := flag.Bool("headless", false, "Run without the gui, commands from stdin (the default when stdin is not a terminal)")
+	commands := flag.String("c", "", "Run the commands \"cmd; cmd\" without the gui and exit")
+	packet := flag.String("packet", "", "When headless where packet output goes, a file, - for stdout or &n for file descriptor n")
+	flag.Parse()
+
+	screen.SetNoColour(*nocolour)
+	if err := screen.SetTheme(*theme); err != nil {
+		fmt.Println("Cannot load theme:", err)
+	}
 	// The prompt for the command view
 	Cinfo.Prompt = "testgocui"
 	Cinfo.Ppad = 3 // len("[]:") // For []: in chars in the prompt e.g. "gocui[5]:"
+	// Pick up the commands from previous sessions
+	if err := Cinfo.LoadHistory(); err != nil {
+		fmt.Println("Cannot load history:", err)
+	}
+	if err := cli.LoadAliases(); err != nil {
+		fmt.Println("Cannot load aliases:", err)
+	}
+	historyReset()
 
-	// Set up the gocui interface and start the mainloop
-	g, err := gocui.NewGui(gocui.OutputNormal)
+	screen.SetDepth(screen.TermDepth())
+	if *nogui || *commands != "" || !isterminal(os.Stdin) {
+		os.Exit(headless(*commands, *packet))
+	}
+
+	// Set up the gocui interface and start the mainloop, it shows at most 256 colours
+	screen.SetDepth(min(screen.TermDepth(), screen.Depth256))
+	g, err := gocui.NewGui(screen.OutputMode())
 	if err != nil {
 		fmt.Printf("Cannot run gocui user interface")
 		log.Fatal(err)
 	}
 	defer g.Close()
+	g.InputEsc = true // A lone Esc is a key, it cancels history search
+	screen.SetReport(screen.Writer(g, "err"))
+	// exit, wherever it is run from, quits once its job has finished
+	cli.OnExit = func(int) {
+		g.Update(func(g *gocui.Gui) error { return quit(g, nil) })
+	}
 
 	g.SetManagerFunc(layout)
 	if err := keybindings(g); err != nil {
@@ -443,7 +619,11 @@ func main() {
 	go mainloop(g, errflag)
 
 	err = <-errflag
-	fmt.Println("Mainloop has quit with error", err.Error())
+	if err != nil && err != gocui.ErrQuit {
+		fmt.Println("Mainloop has quit with error", err.Error())
+	} else {
+		fmt.Println("Gocui Exit. Bye!")
+	}
 	/*
 		select {
 		case err := <-errflag:
@@ -0,0 +1,407 @@
+//go:build linux
+
+// Virtual terminal - Run the gui on a pseudo terminal driven by a script of keys and check
+// the screens against the golden files in testdata/vt, go test -run Golden -update writes them
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/charlesetsmith/testgocui/cli"
+	"github.com/charlesetsmith/testgocui/screen"
+	"github.com/jroimartin/gocui"
+)
+
+var update = flag.Bool("update", false, "Write the golden screens in testdata/vt instead of checking them")
+
+// vterm - The gui running its MainLoop on a pseudo terminal, the keys are sent to pty its master end
+type vterm struct {
+	g     *gocui.Gui
+	pty   *os.File
+	done  chan error      // What the MainLoop returned
+	sync  chan int        // The draws so far when the sync key was handled
+	draws int             // Times the gui has been laid out and drawn, only used from the MainLoop
+	out   strings.Builder // The screens printed so far
+}
+
+// Longest to wait for the gui to handle a key or the commands it started to finish
+var vtwait = 30 * time.Second
+
+// Sent after each key, its handler tells us the keys before it have been handled
+const vtsync = gocui.KeyF12
+
+// What an xterm sends for the keys that are escape sequences, the others are the key's byte.
+// TERMINFO is an empty directory so termbox uses its own xterm keys which are these.
+var vtseqs = map[gocui.Key]string{
+	gocui.KeyArrowUp:    "\033OA",
+	gocui.KeyArrowDown:  "\033OB",
+	gocui.KeyArrowRight: "\033OC",
+	gocui.KeyArrowLeft:  "\033OD",
+	gocui.KeyDelete:     "\033[3~",
+	vtsync:              "\033[24~",
+}
+
+func vtkey(key gocui.Key) string {
+	if s, ok := vtseqs[key]; ok {
+		return s
+	}
+	return string(rune(key))
+}
+
+// Parse a screen size e.g. 80x24
+func vtsize(s string) (int, int, error) {
+	w, h, ok := strings.Cut(s, "x")
+	x, xerr := strconv.Atoi(w)
+	y, yerr := strconv.Atoi(h)
+	if !ok || xerr != nil || yerr != nil || x < 8 || y < 8 {
+		return 0, 0, fmt.Errorf("invalid screen size %q, want e.g. 80x24", s)
+	}
+	return x, y, nil
+}
+
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Set the size of the pseudo terminal, the gui is sent a SIGWINCH
+func vtresize(pty *os.File, x int, y int) error {
+	ws := struct{ row, col, xpixel, ypixel uint16 }{row: uint16(y), col: uint16(x)}
+	return ioctl(pty, syscall.TIOCSWINSZ, unsafe.Pointer(&ws))
+}
+
+// Open a pseudo terminal of x by y, its master end then the terminal
+func openpty(x int, y int) (*os.File, *os.File, error) {
+	var unlock int32
+	var n uint32
+
+	pty, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = ioctl(pty, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err == nil {
+		err = ioctl(pty, syscall.TIOCGPTN, unsafe.Pointer(&n))
+	}
+	if err == nil {
+		err = vtresize(pty, x, y)
+	}
+	if err != nil {
+		pty.Close()
+		return nil, nil, err
+	}
+	tty, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		pty.Close()
+		return nil, nil, err
+	}
+	return pty, tty, nil
+}
+
+// Start the gui on the terminal we have been given as the real one starts it, without
+// any files so each script starts afresh
+func (t *vterm) start() error {
+	cli.Histfile, cli.Rcfile, cli.Aliasfile = "", "", ""
+	Cinfo.Prompt = "testgocui"
+	Cinfo.Ppad = 3
+	historyReset()
+	go io.Copy(io.Discard, t.pty) // What is drawn, the screen is read from gocui's cells instead
+
+	g, err := gocui.NewGui(gocui.OutputNormal)
+	if err != nil {
+		return err
+	}
+	t.g = g
+	g.InputEsc = true
+	screen.SetReport(screen.Writer(g, "err"))
+	cli.OnExit = func(int) {
+		g.Update(func(g *gocui.Gui) error { return quit(g, nil) })
+	}
+	g.SetManagerFunc(func(g *gocui.Gui) error {
+		t.draws++
+		return layout(g)
+	})
+	if err := keybindings(g); err != nil {
+		return err
+	}
+	// Show the view updates waiting for the MainLoop so they are in the screen that follows
+	err = g.SetKeybinding("", vtsync, gocui.ModNone, func(g *gocui.Gui, v *gocui.View) error {
+		err := screen.Flush(g)
+		t.sync <- t.draws
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	go func() { t.done <- g.MainLoop() }()
+	return t.settle()
+}
+
+// Send the sync key and wait for the gui to handle it, which it does after the keys before it
+func (t *vterm) wait() (int, error) {
+	if _, err := io.WriteString(t.pty, vtkey(vtsync)); err != nil {
+		return 0, err
+	}
+	select {
+	case draws := <-t.sync:
+		return draws, nil
+	case err := <-t.done:
+		return 0, err
+	case <-time.After(vtwait):
+		return 0, fmt.Errorf("key not handled after %s", vtwait)
+	}
+}
+
+// Wait for the keys sent to be handled and the commands they started to finish and show their output
+func (t *vterm) settle() error {
+	if _, err := t.wait(); err != nil {
+		return err
+	}
+	idle := make(chan struct{})
+	go func() {
+		cli.Idle()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+	case <-time.After(vtwait):
+		return fmt.Errorf("commands still running after %s", vtwait)
+	}
+	_, err := t.wait()
+	return err
+}
+
+// Send a key and wait for what it does
+func (t *vterm) press(key gocui.Key) error {
+	if _, err := io.WriteString(t.pty, vtkey(key)); err != nil {
+		return err
+	}
+	return t.settle()
+}
+
+// Type s and wait for what it does
+func (t *vterm) typing(s string) error {
+	if _, err := io.WriteString(t.pty, s); err != nil {
+		return err
+	}
+	return t.settle()
+}
+
+// The screen as gocui draws it without the colours then where the cursor is, from the
+// MainLoop once it has drawn everything before the sync key
+func (t *vterm) screen() (string, error) {
+	draws, err := t.wait()
+	for err == nil {
+		shot := make(chan string, 1)
+		t.g.Update(func(g *gocui.Gui) error {
+			if t.draws > draws {
+				shot <- t.cells(g)
+			} else {
+				close(shot)
+			}
+			return nil
+		})
+		select {
+		case s, ok := <-shot:
+			if ok {
+				return s, nil
+			}
+		case err = <-t.done:
+		case <-time.After(vtwait):
+			err = fmt.Errorf("screen not drawn after %s", vtwait)
+		}
+	}
+	return "", err
+}
+
+// The characters gocui last drew in each cell, a line for each row
+func (t *vterm) cells(g *gocui.Gui) string {
+	var s strings.Builder
+
+	maxx, maxy := g.Size()
+	for y := 0; y < maxy; y++ {
+		line := make([]rune, maxx)
+		for x := range line {
+			if line[x], _ = g.Rune(x, y); line[x] == 0 {
+				line[x] = ' '
+			}
+		}
+		s.WriteString(strings.TrimRight(string(line), " ") + "\n")
+	}
+	if v := g.CurrentView(); v != nil {
+		cx, cy := v.Cursor()
+		ox, oy := v.Origin()
+		fmt.Fprintf(&s, "view %s cursor %d,%d origin %d,%d\n", v.Name(), cx, cy, ox, oy)
+	}
+	return s.String()
+}
+
+// Run the script of keys, text is typed and <Name> is a key from keynames, <lt> for a <,
+// <Size WxH> resizes the screen and <Screen> prints it. Newlines and lines starting
+// with # are ignored. It stops at the end of the script or when a key quits.
+func (t *vterm) run(script io.Reader) error {
+	var keys strings.Builder
+
+	scanner := bufio.NewScanner(script)
+	for scanner.Scan() {
+		if !strings.HasPrefix(scanner.Text(), "#") {
+			keys.WriteString(scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	s := keys.String()
+	for s != "" {
+		var err error
+		var shot string
+
+		if s[0] != '<' {
+			ch, size := utf8.DecodeRuneInString(s)
+			err = t.typing(string(ch))
+			s = s[size:]
+		} else {
+			name, rest, ok := strings.Cut(s[1:], ">")
+			if !ok {
+				return fmt.Errorf("missing > after %s", s)
+			}
+			s = rest
+			switch key, found := keynames[name]; {
+			case found:
+				err = t.press(key)
+			case name == "lt":
+				err = t.typing("<")
+			case name == "Screen":
+				if shot, err = t.screen(); err == nil {
+					t.out.WriteString(shot)
+				}
+			case strings.HasPrefix(name, "Size "):
+				var x, y int
+				if x, y, err = vtsize(strings.TrimPrefix(name, "Size ")); err == nil {
+					if err = vtresize(t.pty, x, y); err == nil {
+						err = t.settle()
+					}
+				}
+			default:
+				return fmt.Errorf("unknown key <%s>", name)
+			}
+		}
+		if errors.Is(err, gocui.ErrQuit) {
+			t.out.WriteString("quit\n")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	shot, err := t.screen()
+	if err != nil {
+		return err
+	}
+	t.out.WriteString(shot)
+	return nil
+}
+
+// Set in the helper process that runs a script, see vtmain
+const vtenv = "TESTGOCUI_VT"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(vtenv) != "" {
+		os.Exit(vtmain())
+	}
+	os.Exit(m.Run())
+}
+
+// Run the gui on the pseudo terminal we were given as our controlling terminal (fd 3) with the keys
+// from stdin sent to its master end (fd 4) and print the screens. It is the test binary run again
+// so each script starts afresh.
+func vtmain() int {
+	t := &vterm{pty: os.NewFile(4, "pty"), done: make(chan error, 1), sync: make(chan int, 1)}
+	err := t.start()
+	if err == nil {
+		err = t.run(os.Stdin)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "vt:", err)
+		return 2
+	}
+	fmt.Print(t.out.String())
+	return 0
+}
+
+// Each testdata/vt/name.keys run on an 80x24 screen gives the screens in name.golden
+func TestGolden(t *testing.T) {
+	scripts, err := filepath.Glob("testdata/vt/*.keys")
+	if err != nil || len(scripts) == 0 {
+		t.Fatal("no scripts in testdata/vt", err)
+	}
+	for _, script := range scripts {
+		golden := strings.TrimSuffix(script, ".keys") + ".golden"
+		t.Run(filepath.Base(golden), func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			keys, err := os.Open(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer keys.Close()
+			pty, tty, err := openpty(80, 24)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pty.Close()
+			defer tty.Close()
+			cmd := exec.Command(os.Args[0], "-test.run=^$")
+			// TERMINFO with no terminfo in it so termbox uses its own xterm, see vtseqs
+			cmd.Env = append(os.Environ(), vtenv+"=1", "TERM=xterm", "TERMINFO="+t.TempDir())
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = keys, &stdout, &stderr
+			cmd.ExtraFiles = []*os.File{tty, pty}
+			cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 3}
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("%s: %s\n%s", script, err, stderr.String())
+			}
+			got := stdout.String()
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("screen differs from %s at line %d, got:\n%s", golden, vtdiff(got, string(want)), got)
+			}
+		})
+	}
+}
+
+// First line (from 1) where a and b differ
+func vtdiff(a string, b string) int {
+	al, bl := strings.Split(a, "\n"), strings.Split(b, "\n")
+	for i := range al {
+		if i >= len(bl) || al[i] != bl[i] {
+			return i + 1
+		}
+	}
+	return len(al) + 1
+}